@@ -0,0 +1,588 @@
+/*
+ * file: analytics.go
+ * Description:
+ *   Enrollment statistics over the scraped terms: fill
+ *   rates, waitlists, how fast sections fill, seats by
+ *   time of day and the mix of delivery modes.
+ *
+ *   Each scrape records the seats of every section in
+ *   seat_snapshots, so how fast a section fills can be
+ *   measured between scrapes. Terms with no history yet
+ *   are ranked by how full their sections are now.
+ */
+package api
+
+import (
+	"cmp"
+	"context"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Collection in metaDatabase holding the seats of each section at each scrape
+const snapshotsCollection = "seat_snapshots"
+
+// Rows returned by the ranked analytics when no limit is given
+const defaultAnalyticsLimit = 20
+
+type seatSnapshot struct {
+	Term     string    `bson:"term"`
+	Crn      int       `bson:"crn"`
+	Limit    int       `bson:"limit"`
+	Students int       `bson:"students"`
+	Waiting  int       `bson:"waiting"`
+	At       time.Time `bson:"at"`
+}
+
+type fillRate struct {
+	Subject      string  `bson:"subject" json:"subject"`
+	Term         string  `bson:"term" json:"term"`
+	Sections     int     `bson:"sections" json:"sections"`
+	Seats        int     `bson:"seats" json:"seats"` // Sum of the limits
+	Students     int     `bson:"students" json:"students"`
+	Waiting      int     `bson:"waiting" json:"waiting"`
+	SectionsFull int     `bson:"sectionsfull" json:"sections_full"`
+	FillPercent  float64 `bson:"-" json:"fill_percent"` // Students / Seats * 100
+}
+
+type waitlistedCourse struct {
+	Term     string `bson:"term" json:"term"`
+	Subject  string `bson:"subject" json:"subject"`
+	Number   int    `bson:"number" json:"number"`
+	Title    string `bson:"title" json:"title"`
+	Sections int    `bson:"sections" json:"sections"`
+	Seats    int    `bson:"seats" json:"seats"`
+	Students int    `bson:"students" json:"students"`
+	Waiting  int    `bson:"waiting" json:"waiting"`
+}
+
+type fillingSection struct {
+	Crn         int      `json:"crn"`
+	Course      string   `json:"course"` // CS 125 A
+	Title       string   `json:"title"`
+	Limit       int      `json:"limit"`
+	Students    int      `json:"students"`
+	Waiting     int      `json:"waiting"`
+	FillPercent float64  `json:"fill_percent"`
+	Snapshots   int      `json:"snapshots"`               // Scrapes the section was seen in
+	Filled      bool     `json:"filled"`                  // Reached its limit after the first snapshot
+	HoursToFill *float64 `json:"hours_to_fill,omitempty"` // From the first snapshot to the first full one
+	SeatsPerDay *float64 `json:"seats_per_day,omitempty"` // Seats taken per day until full or the last snapshot
+}
+
+type demandSlot struct {
+	Start       int     `json:"start"` // Minutes after midnight
+	End         int     `json:"end"`
+	Sections    int     `json:"sections"` // Sections meeting during the slot
+	Seats       int     `json:"seats"`
+	Students    int     `json:"students"`
+	Waiting     int     `json:"waiting"`
+	FillPercent float64 `json:"fill_percent"` // (Students + Waiting) / Seats * 100
+}
+
+type modeShare struct {
+	Mode     string  `json:"mode"` // F2F; HYB; unknown when not given
+	Sections int     `json:"sections"`
+	Share    float64 `json:"share"` // Of the sections of the term, 0 to 1
+}
+
+type deliveryMix struct {
+	Term     string      `json:"term"`
+	Sections int         `json:"sections"`
+	Modes    []modeShare `json:"modes"` // Most sections first
+}
+
+/*
+ * Record the seats of courses at the time of a scrape
+ * Arguments:
+ *   ctx : context bounding the write
+ *   term : term of the courses
+ *   courses : courses just inserted
+ */
+func recordSnapshots(ctx context.Context, term string, courses []Course) error {
+	now := time.Now().UTC()
+	snapshots := make([]seatSnapshot, len(courses))
+	for i, c := range courses {
+		snapshots[i] = seatSnapshot{Term: term, Crn: c.Crn, Limit: c.Limit, Students: c.Students, Waiting: c.Waiting, At: now}
+	}
+	_, err := mongoClient.Database(metaDatabase).Collection(snapshotsCollection).InsertMany(ctx, snapshots)
+	return err
+}
+
+/*
+ * Read the limit parameter of a ranking, def when absent
+ */
+func parseLimit(params url.Values, def int) (int, error) {
+	value, err := singleParam(params, "limit")
+	if err != nil || value == "" {
+		return def, err
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 || limit > maxLimit {
+		return 0, badRequestf("limit must be an integer from 1 to %d, got %q", maxLimit, value)
+	}
+	return limit, nil
+}
+
+/*
+ * Read the terms parameter of a request
+ */
+func termsParam(ctx context.Context, params url.Values) ([]string, error) {
+	value, err := singleParam(params, "terms")
+	if err != nil {
+		return nil, err
+	}
+	return selectTerms(ctx, value)
+}
+
+/*
+ * Read every course of a term collection
+ */
+func termCourses(ctx context.Context, db *mongo.Collection) ([]Course, error) {
+	cursor, err := db.Find(ctx, bson.D{}, options.Find().SetProjection(bson.D{{Key: "search", Value: 0}}))
+	if err != nil {
+		return nil, err
+	}
+	courses := []Course{}
+	err = cursor.All(ctx, &courses)
+	return courses, err
+}
+
+/*
+ * Run a pipeline over terms and decode every result into out
+ */
+func aggregateTerms(ctx context.Context, terms []string, filter bson.D, stages mongo.Pipeline, out any) error {
+	db, source := termsPipeline(terms, filter)
+	cursor, err := db.Aggregate(ctx, append(source, stages...))
+	if err != nil {
+		return err
+	}
+	return cursor.All(ctx, out)
+}
+
+/*
+ * GET /v1/analytics/fill-rates?terms=&subject=
+ * Seats filled per subject and term, over the sections with a limit
+ */
+func fillRatesHandler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	if err := checkParams(params, map[string]bool{"terms": true, "subject": true}); err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	terms, err := termsParam(r.Context(), params)
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	subject, err := singleParam(params, "subject")
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+
+	rates := []fillRate{}
+	if len(terms) > 0 {
+		filter := bson.D{{Key: "limit", Value: bson.D{{Key: "$gt", Value: 0}}}}
+		if subject != "" {
+			filter = append(filter, bson.E{Key: "coursecategory", Value: strings.ToUpper(strings.TrimSpace(subject))})
+		}
+		err = aggregateTerms(r.Context(), terms, filter, mongo.Pipeline{
+			{{Key: "$group", Value: bson.D{
+				{Key: "_id", Value: bson.D{{Key: "subject", Value: "$coursecategory"}, {Key: "term", Value: "$term"}}},
+				{Key: "termorder", Value: bson.D{{Key: "$first", Value: "$termorder"}}},
+				{Key: "sections", Value: bson.D{{Key: "$sum", Value: 1}}},
+				{Key: "seats", Value: bson.D{{Key: "$sum", Value: "$limit"}}},
+				{Key: "students", Value: bson.D{{Key: "$sum", Value: "$students"}}},
+				{Key: "waiting", Value: bson.D{{Key: "$sum", Value: "$waiting"}}},
+				{Key: "sectionsfull", Value: bson.D{{Key: "$sum", Value: bson.D{{Key: "$cond", Value: bson.A{
+					bson.D{{Key: "$gte", Value: bson.A{"$students", "$limit"}}}, 1, 0,
+				}}}}}},
+			}}},
+			{{Key: "$set", Value: bson.D{{Key: "subject", Value: "$_id.subject"}, {Key: "term", Value: "$_id.term"}}}},
+			{{Key: "$sort", Value: bson.D{{Key: "subject", Value: 1}, {Key: "termorder", Value: 1}}}},
+		}, &rates)
+		if err != nil {
+			writeErr(w, r, err)
+			return
+		}
+	}
+	for i := range rates {
+		if rates[i].Seats > 0 {
+			rates[i].FillPercent = float64(rates[i].Students) / float64(rates[i].Seats) * 100
+		}
+	}
+	writeData(w, rates, map[string]any{"terms": terms, "count": len(rates)})
+}
+
+/*
+ * GET /v1/analytics/waitlisted?terms=&limit=
+ * Courses with the most students waiting, summed over their sections
+ */
+func waitlistedHandler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	if err := checkParams(params, map[string]bool{"terms": true, "limit": true}); err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	terms, err := termsParam(r.Context(), params)
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	limit, err := parseLimit(params, defaultAnalyticsLimit)
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+
+	courses := []waitlistedCourse{}
+	if len(terms) > 0 {
+		err = aggregateTerms(r.Context(), terms, bson.D{{Key: "waiting", Value: bson.D{{Key: "$gt", Value: 0}}}}, mongo.Pipeline{
+			{{Key: "$group", Value: bson.D{
+				{Key: "_id", Value: bson.D{
+					{Key: "term", Value: "$term"},
+					{Key: "subject", Value: "$coursecategory"},
+					{Key: "number", Value: "$courseid"},
+				}},
+				{Key: "termorder", Value: bson.D{{Key: "$first", Value: "$termorder"}}},
+				{Key: "title", Value: bson.D{{Key: "$first", Value: "$title"}}},
+				{Key: "sections", Value: bson.D{{Key: "$sum", Value: 1}}},
+				{Key: "seats", Value: bson.D{{Key: "$sum", Value: "$limit"}}},
+				{Key: "students", Value: bson.D{{Key: "$sum", Value: "$students"}}},
+				{Key: "waiting", Value: bson.D{{Key: "$sum", Value: "$waiting"}}},
+			}}},
+			{{Key: "$set", Value: bson.D{
+				{Key: "term", Value: "$_id.term"},
+				{Key: "subject", Value: "$_id.subject"},
+				{Key: "number", Value: "$_id.number"},
+			}}},
+			{{Key: "$sort", Value: bson.D{
+				{Key: "waiting", Value: -1},
+				{Key: "termorder", Value: -1},
+				{Key: "subject", Value: 1},
+				{Key: "number", Value: 1},
+			}}},
+			{{Key: "$limit", Value: limit}},
+		}, &courses)
+		if err != nil {
+			writeErr(w, r, err)
+			return
+		}
+	}
+	writeData(w, courses, map[string]any{"terms": terms, "count": len(courses)})
+}
+
+/*
+ * Rank the sections of a term by how fast they filled between scrapes
+ * Arguments:
+ *   ctx : context bounding the query
+ *   term : term of the sections
+ *   sections : sections of the term as they are now, by CRN
+ * Returns:
+ *   the sections with a measurable pace, filled first by
+ *   hours to fill, then the rest by seats taken per day
+ */
+func fillingFromSnapshots(ctx context.Context, term string, sections map[int]Course) ([]fillingSection, error) {
+	cursor, err := mongoClient.Database(metaDatabase).Collection(snapshotsCollection).Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{{Key: "term", Value: term}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "crn", Value: 1}, {Key: "at", Value: 1}}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$crn"},
+			{Key: "snapshots", Value: bson.D{{Key: "$push", Value: "$$ROOT"}}},
+		}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var groups []struct {
+		Crn       int            `bson:"_id"`
+		Snapshots []seatSnapshot `bson:"snapshots"`
+	}
+	if err = cursor.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+
+	ranked := []fillingSection{}
+	for _, g := range groups {
+		c, ok := sections[g.Crn]
+		first := g.Snapshots[0]
+		// Sections full from the start filled before any history was kept
+		if !ok || len(g.Snapshots) < 2 || first.Limit <= 0 || first.Students >= first.Limit {
+			continue
+		}
+		until := g.Snapshots[len(g.Snapshots)-1]
+		filled := false
+		for _, s := range g.Snapshots[1:] {
+			if s.Limit > 0 && s.Students >= s.Limit {
+				until, filled = s, true
+				break
+			}
+		}
+		hours := until.At.Sub(first.At).Hours()
+		if hours <= 0 {
+			continue
+		}
+		f := fillingSnapshot(c, len(g.Snapshots))
+		f.Filled = filled
+		perDay := float64(until.Students-first.Students) / (hours / 24)
+		f.SeatsPerDay = &perDay
+		if filled {
+			f.HoursToFill = &hours
+		}
+		ranked = append(ranked, f)
+	}
+
+	slices.SortFunc(ranked, func(a, b fillingSection) int {
+		switch {
+		case a.Filled && b.Filled:
+			return cmp.Or(cmp.Compare(*a.HoursToFill, *b.HoursToFill), cmp.Compare(a.Crn, b.Crn))
+		case a.Filled != b.Filled:
+			if a.Filled {
+				return -1
+			}
+			return 1
+		}
+		return cmp.Or(cmp.Compare(*b.SeatsPerDay, *a.SeatsPerDay), cmp.Compare(a.Crn, b.Crn))
+	})
+	return ranked, nil
+}
+
+/*
+ * Describe a section as it is now
+ */
+func fillingSnapshot(c Course, snapshots int) fillingSection {
+	return fillingSection{
+		Crn:         c.Crn,
+		Course:      sectionName(&c),
+		Title:       c.Title,
+		Limit:       c.Limit,
+		Students:    c.Students,
+		Waiting:     c.Waiting,
+		FillPercent: c.FillPercent,
+		Snapshots:   snapshots,
+	}
+}
+
+/*
+ * Read the required term parameter and open its collection
+ */
+func analyticsTerm(ctx context.Context, params url.Values) (string, *mongo.Collection, error) {
+	term, err := singleParam(params, "term")
+	if err != nil {
+		return "", nil, err
+	}
+	if term == "" {
+		return "", nil, badRequestf("term is required (e.g.: term=F25)")
+	}
+	db, err := termCollection(ctx, term)
+	return term, db, err
+}
+
+/*
+ * GET /v1/analytics/fastest-filling?term=&limit=
+ * Sections that filled fastest, from the seat snapshots of each scrape.
+ * Without history the sections are ranked by how full they are now.
+ */
+func fastestFillingHandler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	if err := checkParams(params, map[string]bool{"term": true, "limit": true}); err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	limit, err := parseLimit(params, defaultAnalyticsLimit)
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+
+	term, db, err := analyticsTerm(r.Context(), params)
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	courses, err := termCourses(r.Context(), db)
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	sections := map[int]Course{}
+	for _, c := range courses {
+		computeAvailability(&c)
+		sections[c.Crn] = c
+	}
+
+	ranked, err := fillingFromSnapshots(r.Context(), term, sections)
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	source := "snapshots"
+	if len(ranked) == 0 {
+		source = "current"
+		for _, c := range sections {
+			if c.Limit > 0 {
+				ranked = append(ranked, fillingSnapshot(c, 0))
+			}
+		}
+		slices.SortFunc(ranked, func(a, b fillingSection) int {
+			return cmp.Or(
+				cmp.Compare(b.FillPercent, a.FillPercent),
+				cmp.Compare(b.Waiting, a.Waiting),
+				cmp.Compare(a.Crn, b.Crn),
+			)
+		})
+	}
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	writeData(w, ranked, map[string]any{"term": term, "source": source, "count": len(ranked)})
+}
+
+/*
+ * GET /v1/analytics/supply-demand?term=&slot=&days=
+ * Seats offered and wanted by the sections meeting in each slot of the day
+ */
+func supplyDemandHandler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	if err := checkParams(params, map[string]bool{"term": true, "slot": true, "days": true}); err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	slot, err := parseSlot(params)
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	days := []string{}
+	for _, d := range weekDays {
+		days = append(days, string(d))
+	}
+	daysParam, err := singleParam(params, "days")
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	if daysParam != "" {
+		if days = parseDays(&daysParam); len(days) == 0 {
+			writeErr(w, r, badRequestf("days must be letters of %s (e.g.: MWF), got %q", weekDays, daysParam))
+			return
+		}
+	}
+
+	term, db, err := analyticsTerm(r.Context(), params)
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	courses, err := termCourses(r.Context(), db)
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+
+	slots := []demandSlot{}
+	for start := gridStart; start < gridEnd; start += slot {
+		slots = append(slots, demandSlot{Start: start, End: min(start+slot, gridEnd)})
+	}
+	for _, c := range courses {
+		// Terms scraped before meetings existed
+		normaliseCourse(&c)
+		for i := range slots {
+			s := &slots[i]
+			// A section counts once per slot however many of its meetings fall in it
+			meets := slices.ContainsFunc(c.Meetings, func(m Meeting) bool {
+				return m.Start != nil && m.End != nil && *m.Start < s.End && s.Start < *m.End &&
+					slices.ContainsFunc(m.Days, func(d string) bool { return slices.Contains(days, d) })
+			})
+			if !meets {
+				continue
+			}
+			s.Sections++
+			s.Seats += c.Limit
+			s.Students += c.Students
+			s.Waiting += c.Waiting
+		}
+	}
+	for i := range slots {
+		if slots[i].Seats > 0 {
+			slots[i].FillPercent = float64(slots[i].Students+slots[i].Waiting) / float64(slots[i].Seats) * 100
+		}
+	}
+	writeData(w, slots, map[string]any{"term": term, "days": days, "slot": slot})
+}
+
+/*
+ * GET /v1/analytics/delivery-modes?terms=
+ * Share of sections in each delivery mode, term by term
+ */
+func deliveryModesHandler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	if err := checkParams(params, map[string]bool{"terms": true}); err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	terms, err := termsParam(r.Context(), params)
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+
+	var groups []struct {
+		ID struct {
+			Term string `bson:"term"`
+			Mode string `bson:"mode"`
+		} `bson:"_id"`
+		Sections int `bson:"sections"`
+	}
+	if len(terms) > 0 {
+		err = aggregateTerms(r.Context(), terms, bson.D{}, mongo.Pipeline{
+			{{Key: "$group", Value: bson.D{
+				{Key: "_id", Value: bson.D{{Key: "term", Value: "$term"}, {Key: "mode", Value: "$deliverymode"}}},
+				{Key: "sections", Value: bson.D{{Key: "$sum", Value: 1}}},
+			}}},
+		}, &groups)
+		if err != nil {
+			writeErr(w, r, err)
+			return
+		}
+	}
+
+	mix := []deliveryMix{}
+	for _, term := range terms {
+		m := deliveryMix{Term: term, Modes: []modeShare{}}
+		for _, g := range groups {
+			if g.ID.Term != term {
+				continue
+			}
+			mode := strings.ToUpper(strings.TrimSpace(g.ID.Mode))
+			if mode == "" {
+				mode = "unknown"
+			}
+			// Spellings differing in case are one mode
+			k := slices.IndexFunc(m.Modes, func(s modeShare) bool { return s.Mode == mode })
+			if k < 0 {
+				m.Modes = append(m.Modes, modeShare{Mode: mode})
+				k = len(m.Modes) - 1
+			}
+			m.Modes[k].Sections += g.Sections
+			m.Sections += g.Sections
+		}
+		for i := range m.Modes {
+			m.Modes[i].Share = float64(m.Modes[i].Sections) / float64(m.Sections)
+		}
+		slices.SortFunc(m.Modes, func(a, b modeShare) int {
+			return cmp.Or(cmp.Compare(b.Sections, a.Sections), cmp.Compare(a.Mode, b.Mode))
+		})
+		mix = append(mix, m)
+	}
+	writeData(w, mix, map[string]any{"terms": terms, "count": len(mix)})
+}
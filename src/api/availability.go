@@ -0,0 +1,92 @@
+/*
+ * file: availability.go
+ * Description:
+ *   Seat availability computed from the scraped Limit,
+ *   Students and Waiting of a course. The fields are
+ *   stored with the course on insert, and backfilled in
+ *   older terms by backfillAvailability. Queries work out
+ *   open seats from limit and students instead, so they
+ *   match while a term is still being migrated.
+ */
+package api
+
+import (
+	"net/url"
+	"strconv"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Open seats of a course in a query, as computeAvailability works them out
+var openSeatsExpr = bson.D{{Key: "$max", Value: bson.A{
+	bson.D{{Key: "$subtract", Value: bson.A{
+		bson.D{{Key: "$ifNull", Value: bson.A{"$limit", 0}}},
+		bson.D{{Key: "$ifNull", Value: bson.A{"$students", 0}}},
+	}}},
+	0,
+}}}
+
+/*
+ * Fill in the availability fields of a course
+ */
+func computeAvailability(c *Course) {
+	c.OpenSeats = max(c.Limit-c.Students, 0)
+	c.FillPercent = 0
+	if c.Limit > 0 {
+		c.FillPercent = float64(c.Students) / float64(c.Limit) * 100
+	}
+	c.HasWaitlist = c.Waiting > 0
+}
+
+/*
+ * Build the availability conditions, these restrict the
+ * results whatever the filterType
+ * Arguments:
+ *   params : query parameters of the request
+ * Returns:
+ *   one condition per parameter given, or a badRequest
+ */
+func parseAvailabilityFilter(params url.Values) ([]bson.D, error) {
+	conds := []bson.D{}
+
+	hideClosed, err := singleParam(params, "hide_closed")
+	if err != nil {
+		return nil, err
+	}
+	if hideClosed != "" {
+		hide, err := strconv.ParseBool(hideClosed)
+		if err != nil {
+			return nil, badRequestf("hide_closed must be true or false, got %q", hideClosed)
+		}
+		if hide {
+			conds = append(conds, bson.D{{Key: "status", Value: bson.D{
+				{Key: "$not", Value: bson.Regex{Pattern: "^closed", Options: "i"}},
+			}}})
+		}
+	}
+
+	for _, param := range []string{"min_open_seats", "max_waiting"} {
+		value, err := singleParam(params, param)
+		if err != nil {
+			return nil, err
+		}
+		if value == "" {
+			continue
+		}
+		count, err := strconv.Atoi(value)
+		if err != nil || count < 0 {
+			return nil, badRequestf("%s must be a non-negative integer, got %q", param, value)
+		}
+		if param == "min_open_seats" {
+			conds = append(conds, bson.D{{Key: "$expr", Value: bson.D{{Key: "$gte", Value: bson.A{openSeatsExpr, count}}}}})
+			continue
+		}
+		// A course scraped without waiting has none
+		conds = append(conds, bson.D{{Key: "$or", Value: bson.A{
+			bson.D{{Key: "waiting", Value: bson.D{{Key: "$lte", Value: count}}}},
+			bson.D{{Key: "waiting", Value: bson.D{{Key: "$exists", Value: false}}}},
+		}}})
+	}
+
+	return conds, nil
+}
@@ -0,0 +1,176 @@
+/*
+ * file: buildings.go
+ * Description:
+ *   Registry of the campus buildings the roster names by
+ *   code (SLC, BREIS), read from Buildings in config. Each
+ *   meeting gets the name of its building when the courses
+ *   are inserted. Codes missing from the registry are
+ *   flagged on the meeting and recorded in metaDatabase
+ *   so they can be reviewed and added to config.
+ */
+package api
+
+import (
+	"cmp"
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"wilkesu-scrapy/config"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Collection in metaDatabase holding the codes missing from the registry
+const unknownBuildingsCollection = "unknown_buildings"
+
+// Words the roster uses for meetings without a building, either
+// alone or in the free text of a row with no day, time or room
+var notBuildings = map[string]bool{
+	"TBA":        true,
+	"TBD":        true,
+	"ONLINE":     true,
+	"ARRANGED":   true,
+	"ARR":        true,
+	"REMOTE":     true,
+	"VIRTUAL":    true,
+	"OFF-CAMPUS": true,
+}
+
+// Buildings of config by code, read once
+var buildingRegistry = sync.OnceValue(func() map[string]config.Building {
+	registry := map[string]config.Building{}
+	for code, building := range config.LoadConfig().Buildings {
+		registry[strings.ToUpper(code)] = building
+	}
+	return registry
+})
+
+type buildingInfo struct {
+	Code      string  `json:"code"`
+	Name      string  `json:"name"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+type unknownBuilding struct {
+	Code      string    `bson:"_id" json:"code"`
+	Terms     []string  `bson:"terms" json:"terms"`
+	Sections  int       `bson:"sections" json:"sections"` // Meetings using the code in the latest scrape that saw it
+	FirstSeen time.Time `bson:"first_seen" json:"first_seen"`
+	LastSeen  time.Time `bson:"last_seen" json:"last_seen"`
+}
+
+/*
+ * Return the building code of a location, false for
+ * locations that are not a building (TBA, Online, or
+ * text such as "Online Arranged")
+ */
+func buildingCode(location *string) (string, bool) {
+	if location == nil {
+		return "", false
+	}
+	words := strings.Fields(strings.ToUpper(*location))
+	// Codes are one word, longer text is a note rather than a place
+	if len(words) != 1 {
+		return "", false
+	}
+	code := strings.Trim(words[0], ".,;:()")
+	if code == "" || notBuildings[code] {
+		return "", false
+	}
+	return code, true
+}
+
+/*
+ * Fill in the building name of a meeting, or flag its code as unknown
+ */
+func resolveBuilding(m *Meeting) {
+	code, ok := buildingCode(m.Location)
+	if !ok {
+		return
+	}
+	if building, known := buildingRegistry()[code]; known {
+		m.BuildingName = &building.Name
+		return
+	}
+	m.UnknownBuilding = true
+}
+
+/*
+ * Record the building codes of a scraped term missing from the registry
+ * Arguments:
+ *   ctx : context bounding the writes
+ *   term : term the codes were seen in
+ *   codes : meetings seen with each code
+ */
+func recordUnknownBuildings(ctx context.Context, term string, codes map[string]int) error {
+	db := mongoClient.Database(metaDatabase).Collection(unknownBuildingsCollection)
+	now := time.Now().UTC()
+	for code, meetings := range codes {
+		_, err := db.UpdateOne(
+			ctx,
+			bson.D{{Key: "_id", Value: code}},
+			bson.D{
+				{Key: "$addToSet", Value: bson.D{{Key: "terms", Value: term}}},
+				{Key: "$set", Value: bson.D{{Key: "sections", Value: meetings}, {Key: "last_seen", Value: now}}},
+				{Key: "$setOnInsert", Value: bson.D{{Key: "first_seen", Value: now}}},
+			},
+			options.UpdateOne().SetUpsert(true),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+ * GET /v1/buildings
+ * List the buildings of the registry by code
+ */
+func buildingsHandler(w http.ResponseWriter, r *http.Request) {
+	buildings := []buildingInfo{}
+	for code, b := range buildingRegistry() {
+		buildings = append(buildings, buildingInfo{Code: code, Name: b.Name, Latitude: b.Latitude, Longitude: b.Longitude})
+	}
+	slices.SortFunc(buildings, func(a, b buildingInfo) int {
+		return cmp.Compare(a.Code, b.Code)
+	})
+	writeData(w, buildings, map[string]int{"count": len(buildings)})
+}
+
+/*
+ * GET /v1/buildings/unknown
+ * List the building codes scraped that the registry lacks
+ */
+func unknownBuildingsHandler(w http.ResponseWriter, r *http.Request) {
+	cursor, err := mongoClient.Database(metaDatabase).Collection(unknownBuildingsCollection).
+		Find(r.Context(), bson.D{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	found := []unknownBuilding{}
+	if err = cursor.All(r.Context(), &found); err != nil {
+		writeErr(w, r, err)
+		return
+	}
+
+	// Codes added to config since they were recorded are no longer
+	// unknown, and text recorded by older scrapes was never a building
+	registry := buildingRegistry()
+	found = slices.DeleteFunc(found, func(b unknownBuilding) bool {
+		_, known := registry[b.Code]
+		_, isCode := buildingCode(&b.Code)
+		return known || !isCode
+	})
+	for i := range found {
+		sortTerms(found[i].Terms)
+	}
+	writeData(w, found, map[string]int{"count": len(found)})
+}
@@ -0,0 +1,245 @@
+/*
+ * file: calendar.go
+ * Description:
+ *   iCalendar (RFC 5545) export of a set of sections.
+ *   Every meeting becomes one event repeating weekly on
+ *   its days from the first to the last day of classes
+ *   of the term, which come from TermDates in config.
+ *
+ *   Times are written as floating local times (no time
+ *   zone), so the calendar app shows them at the clock
+ *   time on campus and repeats stay right across daylight
+ *   saving changes. Meetings with a TBA time are left out.
+ */
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"wilkesu-scrapy/config"
+)
+
+// Longest line of an iCalendar file in octets, longer lines are folded
+const icsLineLength = 75
+
+// iCalendar names of the roster day letters
+var icsDays = map[string]string{
+	"M": "MO",
+	"T": "TU",
+	"W": "WE",
+	"R": "TH",
+	"F": "FR",
+	"S": "SA",
+	"U": "SU",
+}
+
+// Go weekday of the roster day letters
+var rosterWeekdays = map[string]time.Weekday{
+	"M": time.Monday,
+	"T": time.Tuesday,
+	"W": time.Wednesday,
+	"R": time.Thursday,
+	"F": time.Friday,
+	"S": time.Saturday,
+	"U": time.Sunday,
+}
+
+/*
+ * Escape text for a TEXT property value
+ */
+func icsEscape(text string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(text)
+}
+
+/*
+ * Write one content line, folded every icsLineLength octets
+ * without splitting a UTF-8 character
+ */
+func icsLine(b *strings.Builder, line string) {
+	for len(line) > icsLineLength {
+		cut := icsLineLength
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		b.WriteString(line[:cut] + "\r\n")
+		// Continuation lines start with a space, which counts towards the length
+		line = " " + line[cut:]
+	}
+	b.WriteString(line + "\r\n")
+}
+
+/*
+ * Format a day and minutes after midnight as a floating date-time
+ */
+func icsTime(day time.Time, minutes int) string {
+	return day.Format("20060102") + fmt.Sprintf("T%02d%02d00", minutes/60, minutes%60)
+}
+
+/*
+ * Build the calendar of the sections
+ * Arguments:
+ *   term : term of the sections
+ *   sections : normalised sections to export
+ *   start : first day of classes
+ *   end : last day of classes
+ *   now : time stamping the events
+ */
+func buildCalendar(term string, sections []Course, start time.Time, end time.Time, now time.Time) string {
+	b := &strings.Builder{}
+	icsLine(b, "BEGIN:VCALENDAR")
+	icsLine(b, "VERSION:2.0")
+	icsLine(b, "PRODID:-//wilkesu-scrapy//schedule//EN")
+	icsLine(b, "CALSCALE:GREGORIAN")
+	icsLine(b, "X-WR-CALNAME:"+icsEscape(term+" classes"))
+
+	for _, c := range sections {
+		for n, m := range c.Meetings {
+			if m.Start == nil || m.End == nil || len(m.Days) == 0 {
+				continue
+			}
+
+			// The first class is on the first meeting day on or after start
+			first := start
+			for !meetsOn(m.Days, first.Weekday()) {
+				first = first.AddDate(0, 0, 1)
+			}
+			if first.After(end) {
+				continue
+			}
+
+			byDay := []string{}
+			for _, d := range m.Days {
+				byDay = append(byDay, icsDays[d])
+			}
+
+			summary := sectionName(&c)
+			if c.Title != "" {
+				summary += " " + c.Title
+			}
+			description := []string{"CRN: " + strconv.Itoa(c.Crn)}
+			if c.Instructor != "" {
+				description = append([]string{"Instructor: " + c.Instructor}, description...)
+			}
+
+			icsLine(b, "BEGIN:VEVENT")
+			icsLine(b, fmt.Sprintf("UID:%s-%d-%d@wilkesu-scrapy", term, c.Crn, n))
+			icsLine(b, "DTSTAMP:"+now.UTC().Format("20060102T150405Z"))
+			icsLine(b, "DTSTART:"+icsTime(first, *m.Start))
+			icsLine(b, "DTEND:"+icsTime(first, *m.End))
+			icsLine(b, "RRULE:FREQ=WEEKLY;BYDAY="+strings.Join(byDay, ",")+";UNTIL="+icsTime(end, 24*60-1))
+			icsLine(b, "SUMMARY:"+icsEscape(summary))
+			if location := meetingPlace(m); location != "" {
+				icsLine(b, "LOCATION:"+icsEscape(location))
+			}
+			icsLine(b, "DESCRIPTION:"+icsEscape(strings.Join(description, "\n")))
+			icsLine(b, "END:VEVENT")
+		}
+	}
+
+	icsLine(b, "END:VCALENDAR")
+	return b.String()
+}
+
+/*
+ * Report whether a meeting on days takes place on weekday
+ */
+func meetsOn(days []string, weekday time.Weekday) bool {
+	for _, d := range days {
+		if rosterWeekdays[d] == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+ * Describe where a meeting is (e.g.: Stark Learning Center 108A)
+ */
+func meetingPlace(m Meeting) string {
+	place := ""
+	if m.BuildingName != nil {
+		place = *m.BuildingName
+	} else if m.Location != nil {
+		place = *m.Location
+	}
+	if m.Room != nil {
+		place = strings.TrimSpace(place + " " + *m.Room)
+	}
+	return place
+}
+
+/*
+ * Read the repeated crn parameter of a request
+ * Returns:
+ *   the CRNs without repeats in the order given, or a badRequest
+ */
+func parseCrns(params url.Values) ([]int, error) {
+	values := params["crn"]
+	if len(values) == 0 || len(values) > maxParamValues {
+		return nil, badRequestf("crn must be given from 1 to %d times", maxParamValues)
+	}
+	crns := []int{}
+	for _, value := range values {
+		crn, err := strconv.Atoi(value)
+		if err != nil || crn <= 0 {
+			return nil, badRequestf("crn must be a positive integer, got %q", value)
+		}
+		if !slices.Contains(crns, crn) {
+			crns = append(crns, crn)
+		}
+	}
+	return crns, nil
+}
+
+/*
+ * GET /v1/terms/{term}/calendar.ics?crn=
+ * Export the sections with the given CRNs as an iCalendar file
+ * Arguments:
+ *   cfg : configuration holding the dates of each term
+ */
+func calendarHandler(cfg config.Configuration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+		if err := checkParams(params, map[string]bool{"crn": true}); err != nil {
+			writeErr(w, r, err)
+			return
+		}
+		crns, err := parseCrns(params)
+		if err != nil {
+			writeErr(w, r, err)
+			return
+		}
+
+		term := r.PathValue("term")
+		db, err := termCollection(r.Context(), term)
+		if err != nil {
+			writeErr(w, r, err)
+			return
+		}
+		start, end, ok := cfg.TermRange(term)
+		if !ok {
+			writeErr(w, r, notFoundf("no dates of classes are configured for %s", term))
+			return
+		}
+
+		sections, missing, err := findCrns(r.Context(), db, crns)
+		if err != nil {
+			writeErr(w, r, err)
+			return
+		}
+		if len(missing) > 0 {
+			writeErr(w, r, notFoundf("crns not offered in %s: %s", term, joinInts(missing)))
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+term+`.ics"`)
+		fmt.Fprint(w, buildCalendar(term, sections, start, end, time.Now()))
+	}
+}
@@ -0,0 +1,148 @@
+package api
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"wilkesu-scrapy/config"
+)
+
+func TestIcsLine(t *testing.T) {
+	tests := []struct {
+		name      string
+		line      string
+		wantLines int
+	}{
+		{"short", "VERSION:2.0", 1},
+		{"exactly 75 octets", strings.Repeat("a", 75), 1},
+		{"76 octets", strings.Repeat("a", 76), 2},
+		{"long", "DESCRIPTION:" + strings.Repeat("x", 200), 3},
+		{"multibyte at the fold", strings.Repeat("a", 74) + "é" + strings.Repeat("b", 10), 2},
+		{"multibyte throughout", "SUMMARY:" + strings.Repeat("日本", 40), 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &strings.Builder{}
+			icsLine(b, tt.line)
+			out := b.String()
+			if !strings.HasSuffix(out, "\r\n") {
+				t.Fatalf("icsLine() = %q, want it to end in CRLF", out)
+			}
+
+			lines := strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n")
+			if len(lines) != tt.wantLines {
+				t.Errorf("got %d lines, want %d: %q", len(lines), tt.wantLines, lines)
+			}
+			for i, l := range lines {
+				if len(l) > icsLineLength {
+					t.Errorf("line %d is %d octets, want at most %d", i, len(l), icsLineLength)
+				}
+				if !utf8.ValidString(l) {
+					t.Errorf("line %d splits a UTF-8 character: %q", i, l)
+				}
+				if i > 0 && !strings.HasPrefix(l, " ") {
+					t.Errorf("continuation line %d does not start with a space: %q", i, l)
+				}
+			}
+			// Unfolding removes each CRLF and the space after it
+			if unfolded := strings.ReplaceAll(strings.TrimSuffix(out, "\r\n"), "\r\n ", ""); unfolded != tt.line {
+				t.Errorf("unfolded = %q, want %q", unfolded, tt.line)
+			}
+		})
+	}
+}
+
+/*
+ * Return the value of the first property called name in an iCalendar file
+ */
+func icsProperty(calendar string, name string) string {
+	for _, line := range strings.Split(calendar, "\r\n") {
+		if value, found := strings.CutPrefix(line, name+":"); found {
+			return value
+		}
+	}
+	return ""
+}
+
+func TestBuildCalendarDates(t *testing.T) {
+	tests := []struct {
+		name      string
+		dates     config.TermDates
+		days      string
+		wantStart string
+		wantEnd   string
+		wantRule  string
+	}{
+		{
+			name:      "first class on the first day",
+			dates:     config.TermDates{Start: "2025-08-25", End: "2025-12-12"},
+			days:      "MWF",
+			wantStart: "20250825T090000",
+			wantEnd:   "20250825T095000",
+			wantRule:  "FREQ=WEEKLY;BYDAY=MO,WE,FR;UNTIL=20251212T235900",
+		},
+		{
+			name:      "first class later in the first week",
+			dates:     config.TermDates{Start: "2025-08-25", End: "2025-12-12"},
+			days:      "TR",
+			wantStart: "20250826T090000",
+			wantEnd:   "20250826T095000",
+			wantRule:  "FREQ=WEEKLY;BYDAY=TU,TH;UNTIL=20251212T235900",
+		},
+		{
+			name:      "term starting midweek",
+			dates:     config.TermDates{Start: "2026-01-21", End: "2026-05-08"},
+			days:      "M",
+			wantStart: "20260126T090000",
+			wantEnd:   "20260126T095000",
+			wantRule:  "FREQ=WEEKLY;BYDAY=MO;UNTIL=20260508T235900",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Configuration{TermDates: map[string]config.TermDates{"F25": tt.dates}}
+			start, end, ok := cfg.TermRange("F25")
+			if !ok {
+				t.Fatalf("TermRange() not ok for %+v", tt.dates)
+			}
+			section := testSection(40001, tt.days, 540, 590)
+			calendar := buildCalendar("F25", []Course{section}, start, end, time.Now())
+
+			if got := icsProperty(calendar, "DTSTART"); got != tt.wantStart {
+				t.Errorf("DTSTART = %q, want %q", got, tt.wantStart)
+			}
+			if got := icsProperty(calendar, "DTEND"); got != tt.wantEnd {
+				t.Errorf("DTEND = %q, want %q", got, tt.wantEnd)
+			}
+			if got := icsProperty(calendar, "RRULE"); got != tt.wantRule {
+				t.Errorf("RRULE = %q, want %q", got, tt.wantRule)
+			}
+		})
+	}
+}
+
+func TestBuildCalendarSkipsMeetings(t *testing.T) {
+	start := time.Date(2025, 8, 25, 0, 0, 0, 0, time.UTC) // Monday
+	end := time.Date(2025, 8, 27, 0, 0, 0, 0, time.UTC)   // Wednesday
+	nine := 540
+
+	timed := testSection(1, "MWF", 540, 590)
+	timed.Meetings = append(timed.Meetings, Meeting{Days: []string{"W"}}) // TBA extra row
+	tba := Course{Crn: 2, Meetings: []Meeting{{Days: []string{"M"}}}}
+	noDays := Course{Crn: 3, Meetings: []Meeting{{Days: []string{}, Start: &nine, End: &nine}}}
+	startOnly := Course{Crn: 4, Meetings: []Meeting{{Days: []string{"T"}, Start: &nine}}}
+	afterEnd := testSection(5, "F", 540, 590) // First Friday is past the end
+
+	calendar := buildCalendar("F25", []Course{timed, tba, noDays, startOnly, afterEnd}, start, end, time.Now())
+	if got := strings.Count(calendar, "BEGIN:VEVENT"); got != 1 {
+		t.Errorf("got %d events, want 1:\n%s", got, calendar)
+	}
+	if !strings.Contains(calendar, "UID:F25-1-0@wilkesu-scrapy") {
+		t.Errorf("calendar lacks the event of the timed meeting:\n%s", calendar)
+	}
+	if !strings.HasPrefix(calendar, "BEGIN:VCALENDAR\r\n") || !strings.HasSuffix(calendar, "END:VCALENDAR\r\n") {
+		t.Errorf("calendar is not wrapped in VCALENDAR:\n%s", calendar)
+	}
+}
@@ -0,0 +1,286 @@
+/*
+ * file: catalog.go
+ * Description:
+ *   Catalog view of a course, e.g. MTH 111, summarising
+ *   every section of it in every scraped term. Sections
+ *   are normalised again when read so terms scraped
+ *   before meetings and availability existed still count.
+ */
+package api
+
+import (
+	"cmp"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type catalogTitle struct {
+	Title string   `json:"title"`
+	Terms []string `json:"terms"`
+}
+
+type catalogTerm struct {
+	Term        string   `json:"term"`
+	Sections    int      `json:"sections"`
+	Instructors []string `json:"instructors"`
+	Students    int      `json:"students"`
+	Limit       int      `json:"limit"`
+}
+
+type meetingPattern struct {
+	Days     string `json:"days"`            // MWF; TR; "" for TBA
+	Start    *int   `json:"start,omitempty"` // Minutes after midnight
+	End      *int   `json:"end,omitempty"`
+	Sections int    `json:"sections"`
+}
+
+type buildingCount struct {
+	Building string `json:"building"`
+	Sections int    `json:"sections"`
+}
+
+type catalogMode struct {
+	Mode     string `json:"mode"`
+	Sections int    `json:"sections"`
+}
+
+type catalogEnrollment struct {
+	MedianStudents  float64 `json:"median_students"`
+	MedianLimit     float64 `json:"median_limit"`
+	MeanFillPercent float64 `json:"mean_fill_percent"`
+	SectionsFull    int     `json:"sections_full"`
+	FillRate        float64 `json:"fill_rate"` // Share of the sections with a limit that filled, 0 to 1
+}
+
+type catalogEntry struct {
+	Subject         string            `json:"subject"`
+	Number          int               `json:"number"`
+	Sections        int               `json:"sections"`
+	Titles          []catalogTitle    `json:"titles"`
+	Credits         []float32         `json:"credits"`
+	Terms           []catalogTerm     `json:"terms"`
+	MeetingPatterns []meetingPattern  `json:"meeting_patterns"`
+	DeliveryModes   []catalogMode     `json:"delivery_modes"`
+	Enrollment      catalogEnrollment `json:"enrollment"`
+}
+
+/*
+ * Return the median of values, 0 when there are none
+ */
+func median(values []int) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return float64(sorted[mid-1]+sorted[mid]) / 2
+	}
+	return float64(sorted[mid])
+}
+
+/*
+ * Count the sections meeting at each combination of days and
+ * times, a section meeting twice at the same time counts once
+ * Returns:
+ *   the patterns, most common first
+ */
+func meetingPatterns(sections []termCourse) []meetingPattern {
+	patterns := []meetingPattern{}
+	index := map[string]int{}
+	for _, s := range sections {
+		seen := map[string]bool{}
+		for _, m := range s.Meetings {
+			key := strings.Join(m.Days, "")
+			if m.Start != nil && m.End != nil {
+				key += " " + strconv.Itoa(*m.Start) + "-" + strconv.Itoa(*m.End)
+			}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			if i, ok := index[key]; ok {
+				patterns[i].Sections++
+				continue
+			}
+			index[key] = len(patterns)
+			patterns = append(patterns, meetingPattern{
+				Days:     strings.Join(m.Days, ""),
+				Start:    m.Start,
+				End:      m.End,
+				Sections: 1,
+			})
+		}
+	}
+	slices.SortStableFunc(patterns, func(a, b meetingPattern) int {
+		return cmp.Compare(b.Sections, a.Sections)
+	})
+	return patterns
+}
+
+/*
+ * Count the sections meeting in each building
+ * Returns:
+ *   the buildings, most used first
+ */
+func buildingCounts(sections []termCourse) []buildingCount {
+	buildings := []buildingCount{}
+	index := map[string]int{}
+	for _, s := range sections {
+		seen := map[string]bool{}
+		for _, m := range s.Meetings {
+			if m.Location == nil || *m.Location == "" || seen[*m.Location] {
+				continue
+			}
+			seen[*m.Location] = true
+			if i, ok := index[*m.Location]; ok {
+				buildings[i].Sections++
+				continue
+			}
+			index[*m.Location] = len(buildings)
+			buildings = append(buildings, buildingCount{Building: *m.Location, Sections: 1})
+		}
+	}
+	slices.SortStableFunc(buildings, func(a, b buildingCount) int {
+		return cmp.Compare(b.Sections, a.Sections)
+	})
+	return buildings
+}
+
+/*
+ * Summarise the sections of a course
+ * Arguments:
+ *   subject : subject of the course (e.g.: MTH)
+ *   number : number of the course (e.g.: 111)
+ *   sections : every section of it, oldest term first
+ */
+func buildCatalog(subject string, number int, sections []termCourse) catalogEntry {
+	entry := catalogEntry{
+		Subject:         subject,
+		Number:          number,
+		Sections:        len(sections),
+		Titles:          []catalogTitle{},
+		Credits:         []float32{},
+		Terms:           []catalogTerm{},
+		MeetingPatterns: meetingPatterns(sections),
+		DeliveryModes:   []catalogMode{},
+	}
+
+	titles := map[string]int{}
+	modes := map[string]int{}
+	students := []int{}
+	limits := []int{}
+	fillTotal := 0.0
+	for _, s := range sections {
+		c := s.Course
+
+		if i, ok := titles[c.Title]; !ok {
+			titles[c.Title] = len(entry.Titles)
+			entry.Titles = append(entry.Titles, catalogTitle{Title: c.Title, Terms: []string{s.Term}})
+		} else if !slices.Contains(entry.Titles[i].Terms, s.Term) {
+			entry.Titles[i].Terms = append(entry.Titles[i].Terms, s.Term)
+		}
+
+		if !slices.Contains(entry.Credits, c.Credits) {
+			entry.Credits = append(entry.Credits, c.Credits)
+		}
+
+		if len(entry.Terms) == 0 || entry.Terms[len(entry.Terms)-1].Term != s.Term {
+			entry.Terms = append(entry.Terms, catalogTerm{Term: s.Term, Instructors: []string{}})
+		}
+		term := &entry.Terms[len(entry.Terms)-1]
+		term.Sections++
+		term.Students += c.Students
+		term.Limit += c.Limit
+		if c.Instructor != "" && !slices.Contains(term.Instructors, c.Instructor) {
+			term.Instructors = append(term.Instructors, c.Instructor)
+		}
+
+		if i, ok := modes[c.DeliveryMode]; ok {
+			entry.DeliveryModes[i].Sections++
+		} else {
+			modes[c.DeliveryMode] = len(entry.DeliveryModes)
+			entry.DeliveryModes = append(entry.DeliveryModes, catalogMode{Mode: c.DeliveryMode, Sections: 1})
+		}
+
+		// Sections without a limit say nothing about demand
+		if c.Limit > 0 {
+			students = append(students, c.Students)
+			limits = append(limits, c.Limit)
+			fillTotal += c.FillPercent
+			if c.Students >= c.Limit {
+				entry.Enrollment.SectionsFull++
+			}
+		}
+	}
+
+	slices.Sort(entry.Credits)
+	for i := range entry.Terms {
+		slices.Sort(entry.Terms[i].Instructors)
+	}
+	slices.SortStableFunc(entry.DeliveryModes, func(a, b catalogMode) int {
+		return cmp.Compare(b.Sections, a.Sections)
+	})
+
+	entry.Enrollment.MedianStudents = median(students)
+	entry.Enrollment.MedianLimit = median(limits)
+	if len(limits) > 0 {
+		entry.Enrollment.MeanFillPercent = fillTotal / float64(len(limits))
+		entry.Enrollment.FillRate = float64(entry.Enrollment.SectionsFull) / float64(len(limits))
+	}
+	return entry
+}
+
+/*
+ * GET /v1/catalog/{subject}/{number}
+ * Summarise every section of a course across all terms
+ */
+func catalogHandler(w http.ResponseWriter, r *http.Request) {
+	subject := strings.ToUpper(r.PathValue("subject"))
+	number, err := strconv.Atoi(r.PathValue("number"))
+	if err != nil || number <= 0 {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "number must be a positive integer")
+		return
+	}
+
+	terms, err := listTerms(r.Context())
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	sections := []termCourse{}
+	if len(terms) > 0 {
+		db, source := termsPipeline(terms, bson.D{
+			{Key: "coursecategory", Value: subject},
+			{Key: "courseid", Value: number},
+		})
+		source = append(source, bson.D{{Key: "$sort", Value: bson.D{
+			{Key: "termorder", Value: 1},
+			{Key: "section", Value: 1},
+		}}})
+		cursor, err := db.Aggregate(r.Context(), source)
+		if err != nil {
+			writeErr(w, r, err)
+			return
+		}
+		if err = cursor.All(r.Context(), &sections); err != nil {
+			writeErr(w, r, err)
+			return
+		}
+	}
+	if len(sections) == 0 {
+		writeError(w, http.StatusNotFound, codeNotFound, "unknown course: "+subject+" "+strconv.Itoa(number))
+		return
+	}
+
+	for i := range sections {
+		normaliseCourse(&sections[i].Course)
+		computeAvailability(&sections[i].Course)
+	}
+	writeData(w, buildCatalog(subject, number, sections), nil)
+}
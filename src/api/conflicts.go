@@ -0,0 +1,190 @@
+/*
+ * file: conflicts.go
+ * Description:
+ *   Checks a set of CRNs a student intends to register
+ *   for: sections meeting at the same time, sections
+ *   that are closed, two sections of the same course and
+ *   the credits the set adds up to. Back-to-back meetings
+ *   too far apart to walk between are warned about but do
+ *   not stop the set from being ok.
+ */
+package api
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type conflictRequest struct {
+	Crns []int `json:"crns"`
+}
+
+// Two meetings of different sections at the same time
+type meetingOverlap struct {
+	First  Meeting  `json:"first"`
+	Second Meeting  `json:"second"`
+	Days   []string `json:"days"`  // Days both meet on
+	Start  int      `json:"start"` // Overlapping window, minutes after midnight
+	End    int      `json:"end"`
+}
+
+type timeConflict struct {
+	Crns     [2]int           `json:"crns"`
+	Courses  [2]string        `json:"courses"` // CS 125 A
+	Meetings []meetingOverlap `json:"meetings"`
+}
+
+type closedSection struct {
+	Crn    int    `json:"crn"`
+	Course string `json:"course"`
+	Status string `json:"status"`
+}
+
+type duplicateCourse struct {
+	Subject string `json:"subject"`
+	Number  int    `json:"number"`
+	Crns    []int  `json:"crns"`
+}
+
+type conflictReport struct {
+	Term       string            `json:"term"`
+	Ok         bool              `json:"ok"` // Nothing below stands in the way of registering
+	Credits    float32           `json:"credits"`
+	Conflicts  []timeConflict    `json:"conflicts"`
+	Closed     []closedSection   `json:"closed"`
+	Duplicates []duplicateCourse `json:"duplicates"`
+	Missing    []int             `json:"missing"` // CRNs not offered in the term
+	Walking    []walkWarning     `json:"walking"` // Tight transitions, see walking.go
+	Sections   []Course          `json:"sections"`
+}
+
+/*
+ * Name a section the way students write it (e.g.: CS 125 A)
+ */
+func sectionName(c *Course) string {
+	return c.CourseCategory + " " + strconv.Itoa(c.CourseId) + " " + c.Section
+}
+
+/*
+ * Check a set of sections for clashes
+ * Arguments:
+ *   term : term of the sections
+ *   sections : the sections found, normalised
+ *   missing : CRNs that were not found
+ */
+func checkConflicts(term string, sections []Course, missing []int) conflictReport {
+	report := conflictReport{
+		Term:       term,
+		Conflicts:  []timeConflict{},
+		Closed:     []closedSection{},
+		Duplicates: []duplicateCourse{},
+		Missing:    missing,
+		Walking:    walkingWarnings(sections),
+		Sections:   sections,
+	}
+
+	for i := range sections {
+		a := &sections[i]
+		report.Credits += a.Credits
+
+		if strings.EqualFold(a.Status, "closed") {
+			report.Closed = append(report.Closed, closedSection{Crn: a.Crn, Course: sectionName(a), Status: a.Status})
+		}
+
+		for j := i + 1; j < len(sections); j++ {
+			b := &sections[j]
+			conflict := timeConflict{
+				Crns:     [2]int{a.Crn, b.Crn},
+				Courses:  [2]string{sectionName(a), sectionName(b)},
+				Meetings: []meetingOverlap{},
+			}
+			for _, ma := range a.Meetings {
+				for _, mb := range b.Meetings {
+					if !meetingsOverlap(ma, mb) {
+						continue
+					}
+					overlap := meetingOverlap{
+						First:  ma,
+						Second: mb,
+						Days:   []string{},
+						Start:  max(*ma.Start, *mb.Start),
+						End:    min(*ma.End, *mb.End),
+					}
+					for _, day := range ma.Days {
+						if slices.Contains(mb.Days, day) {
+							overlap.Days = append(overlap.Days, day)
+						}
+					}
+					conflict.Meetings = append(conflict.Meetings, overlap)
+				}
+			}
+			if len(conflict.Meetings) > 0 {
+				report.Conflicts = append(report.Conflicts, conflict)
+			}
+		}
+	}
+
+	// Sections of the same course, in the order first seen
+	for i := range sections {
+		c := &sections[i]
+		k := slices.IndexFunc(report.Duplicates, func(d duplicateCourse) bool {
+			return d.Subject == c.CourseCategory && d.Number == c.CourseId
+		})
+		if k < 0 {
+			report.Duplicates = append(report.Duplicates, duplicateCourse{
+				Subject: c.CourseCategory,
+				Number:  c.CourseId,
+				Crns:    []int{},
+			})
+			k = len(report.Duplicates) - 1
+		}
+		report.Duplicates[k].Crns = append(report.Duplicates[k].Crns, c.Crn)
+	}
+	report.Duplicates = slices.DeleteFunc(report.Duplicates, func(d duplicateCourse) bool {
+		return len(d.Crns) < 2
+	})
+
+	report.Ok = len(report.Conflicts) == 0 && len(report.Closed) == 0 &&
+		len(report.Duplicates) == 0 && len(report.Missing) == 0
+	return report
+}
+
+/*
+ * POST /v1/terms/{term}/conflicts
+ * Check the CRNs of a JSON body {"crns": [...]} for clashes
+ */
+func conflictsHandler(w http.ResponseWriter, r *http.Request) {
+	var req conflictRequest
+	if err := readJSON(w, r, &req); err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	if len(req.Crns) == 0 || len(req.Crns) > maxParamValues {
+		writeErr(w, r, badRequestf("crns must hold from 1 to %d CRNs", maxParamValues))
+		return
+	}
+	crns := []int{}
+	for _, crn := range req.Crns {
+		if crn <= 0 {
+			writeErr(w, r, badRequestf("crns must be positive integers"))
+			return
+		}
+		if !slices.Contains(crns, crn) {
+			crns = append(crns, crn)
+		}
+	}
+
+	db, err := termCollection(r.Context(), r.PathValue("term"))
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	sections, missing, err := findCrns(r.Context(), db, crns)
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	writeData(w, checkConflicts(r.PathValue("term"), sections, missing), nil)
+}
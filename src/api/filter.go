@@ -0,0 +1,443 @@
+/*
+ * file: filter.go
+ * Description:
+ *   Validates the query parameters of a course search
+ *   and builds the MongoDB filter from them. Each field
+ *   matches any of its values, values prefixed with !
+ *   are excluded, and filterType chooses whether a course
+ *   must match all of the fields or any of them. Any problem
+ *   with the parameters is returned as a badRequest so
+ *   the handler can answer with a 400 describing it.
+ */
+package api
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Longest value accepted for a text parameter
+const maxParamLength = 100
+
+// Most values accepted for a repeatable parameter
+const maxParamValues = 20
+
+// A problem with the request reported back to the client as a 400
+type badRequest struct {
+	msg string
+}
+
+func (e badRequest) Error() string {
+	return e.msg
+}
+
+func badRequestf(format string, args ...any) error {
+	return badRequest{msg: fmt.Sprintf(format, args...)}
+}
+
+// Text parameters of /filter and the course field each one matches
+var textParams = []struct {
+	param string
+	field string
+}{
+	{"deliverymode", "deliverymode"},
+	{"category", "coursecategory"},
+	{"location", "location"},
+	{"instructor", "instructor"},
+	{"status", "status"},
+}
+
+// Integer parameters of /filter and the course field each one equals
+var intParams = []struct {
+	param string
+	field string
+}{
+	{"crn", "crn"},
+	{"number", "courseid"},
+}
+
+// Every parameter filtering courses
+var filterParams = map[string]bool{
+	"deliverymode":   true,
+	"category":       true,
+	"credits":        true,
+	"location":       true,
+	"instructor":     true,
+	"status":         true,
+	"crn":            true,
+	"number":         true,
+	"filterType":     true,
+	"filtertype":     true, // Alias of filterType
+	"days":           true,
+	"daysmatch":      true,
+	"earliest_start": true,
+	"latest_end":     true,
+	"hide_closed":    true,
+	"min_open_seats": true,
+	"max_waiting":    true,
+}
+
+/*
+ * Return the single value of a parameter
+ * Arguments:
+ *   params : query parameters of the request
+ *   name : parameter to read
+ * Returns:
+ *   the value ("" when absent) or a badRequest if it is repeated or too long
+ */
+func singleParam(params url.Values, name string) (string, error) {
+	values := params[name]
+	if len(values) == 0 {
+		return "", nil
+	}
+	if len(values) > 1 {
+		return "", badRequestf("%s may only be given once", name)
+	}
+	if len(values[0]) > maxParamLength {
+		return "", badRequestf("%s must be at most %d characters", name, maxParamLength)
+	}
+	return values[0], nil
+}
+
+/*
+ * Build a case insensitive match of the literal text
+ * value, regex metacharacters in it are escaped
+ */
+func containsText(value string) bson.Regex {
+	return bson.Regex{Pattern: regexp.QuoteMeta(value), Options: "i"}
+}
+
+/*
+ * Return every value of a parameter split into the values to
+ * match and the values to exclude, which are prefixed with !
+ * Arguments:
+ *   params : query parameters of the request
+ *   name : parameter to read
+ * Returns:
+ *   the values to match, the values to exclude, or a badRequest
+ */
+func splitValues(params url.Values, name string) ([]string, []string, error) {
+	values := params[name]
+	if len(values) > maxParamValues {
+		return nil, nil, badRequestf("%s may be given at most %d times", name, maxParamValues)
+	}
+	include := []string{}
+	exclude := []string{}
+	for _, value := range values {
+		if len(value) > maxParamLength {
+			return nil, nil, badRequestf("%s must be at most %d characters", name, maxParamLength)
+		}
+		if negated, found := strings.CutPrefix(value, "!"); found {
+			if negated == "" {
+				return nil, nil, badRequestf("%s=! needs a value to exclude", name)
+			}
+			exclude = append(exclude, negated)
+		} else if value != "" {
+			include = append(include, value)
+		}
+	}
+	return include, exclude, nil
+}
+
+/*
+ * Combine the conditions of the fields, either all of them
+ * (intersection) or any of them (union). Exclusions always apply.
+ */
+func composeFilter(conds []bson.D, excludes []bson.D, union bool) bson.D {
+	and := bson.A{}
+	if union && len(conds) > 1 {
+		or := bson.A{}
+		for _, cond := range conds {
+			or = append(or, cond)
+		}
+		and = append(and, bson.D{{Key: "$or", Value: or}})
+	} else {
+		for _, cond := range conds {
+			and = append(and, cond)
+		}
+	}
+	for _, exclude := range excludes {
+		and = append(and, exclude)
+	}
+	if len(and) == 0 {
+		return bson.D{}
+	}
+	return bson.D{{Key: "$and", Value: and}}
+}
+
+/*
+ * Reject any parameter that is not in one of the allowed sets
+ */
+func checkParams(params url.Values, allowed ...map[string]bool) error {
+	for name := range params {
+		known := false
+		for _, set := range allowed {
+			known = known || set[name]
+		}
+		if !known {
+			return badRequestf("unknown parameter: %s", name)
+		}
+	}
+	return nil
+}
+
+/*
+ * Validate the /filter parameters and build the course filter
+ * Arguments:
+ *   params : query parameters of the request
+ * Returns:
+ *   the term to search, the filter, or a badRequest describing the first problem
+ */
+func parseFilter(params url.Values) (string, bson.D, error) {
+	if err := checkParams(params, map[string]bool{"semester": true}, filterParams, pageParams); err != nil {
+		return "", nil, err
+	}
+
+	semester, err := singleParam(params, "semester")
+	if err != nil {
+		return "", nil, err
+	}
+	if semester == "" {
+		return "", nil, badRequestf("semester is required (e.g.: semester=F25)")
+	}
+	if _, _, ok := parseTerm(semester); !ok {
+		return "", nil, badRequestf("semester must be F or Sp followed by a year (e.g.: F25), got %q", semester)
+	}
+
+	filter, err := parseCourseFilter(params)
+	return semester, filter, err
+}
+
+/*
+ * Build the course filter from the filter parameters
+ * Arguments:
+ *   params : query parameters of the request
+ * Returns:
+ *   the filter, or a badRequest describing the first problem
+ */
+func parseCourseFilter(params url.Values) (bson.D, error) {
+	// Conditions of each field, composed according to filterType,
+	// and exclusions which always apply
+	conds := []bson.D{}
+	excludes := []bson.D{}
+
+	// The frontend sends filterType, filtertype is accepted as an alias
+	filterType, err := singleParam(params, "filterType")
+	if err != nil {
+		return nil, err
+	}
+	alias, err := singleParam(params, "filtertype")
+	if err != nil {
+		return nil, err
+	}
+	if alias != "" {
+		if filterType != "" {
+			return nil, badRequestf("filterType may only be given once, not also as filtertype")
+		}
+		filterType = alias
+	}
+	union := false
+	switch strings.ToLower(filterType) {
+	case "", "i", "intersection":
+	case "u", "union":
+		union = true
+	default:
+		return nil, badRequestf("filterType must be intersection or union, got %q", filterType)
+	}
+
+	// Handle string parameters, a field matches any of its values
+	for _, p := range textParams {
+		include, exclude, err := splitValues(params, p.param)
+		if err != nil {
+			return nil, err
+		}
+		if len(include) > 0 {
+			regexes := bson.A{}
+			for _, value := range include {
+				regexes = append(regexes, containsText(value))
+			}
+			conds = append(conds, bson.D{{Key: p.field, Value: bson.D{{Key: "$in", Value: regexes}}}})
+		}
+		if len(exclude) > 0 {
+			regexes := bson.A{}
+			for _, value := range exclude {
+				regexes = append(regexes, containsText(value))
+			}
+			excludes = append(excludes, bson.D{{Key: p.field, Value: bson.D{{Key: "$nin", Value: regexes}}}})
+		}
+	}
+
+	// Handle number parameters
+	include, exclude, err := splitValues(params, "credits")
+	if err != nil {
+		return nil, err
+	}
+	creditRange := func(credits string) (bson.D, error) {
+		creditsFloat, err := strconv.ParseFloat(credits, 64)
+		if err != nil || creditsFloat < 0 {
+			return nil, badRequestf("credits must be a non-negative number, got %q", credits)
+		}
+		// Credits are stored as float32, so match within a hundredth
+		return bson.D{{Key: "credits", Value: bson.D{
+			{Key: "$gte", Value: creditsFloat - 0.005},
+			{Key: "$lt", Value: creditsFloat + 0.005},
+		}}}, nil
+	}
+	if len(include) > 0 {
+		or := bson.A{}
+		for _, credits := range include {
+			cond, err := creditRange(credits)
+			if err != nil {
+				return nil, err
+			}
+			or = append(or, cond)
+		}
+		conds = append(conds, bson.D{{Key: "$or", Value: or}})
+	}
+	if len(exclude) > 0 {
+		nor := bson.A{}
+		for _, credits := range exclude {
+			cond, err := creditRange(credits)
+			if err != nil {
+				return nil, err
+			}
+			nor = append(nor, cond)
+		}
+		excludes = append(excludes, bson.D{{Key: "$nor", Value: nor}})
+	}
+
+	for _, p := range intParams {
+		include, exclude, err := splitValues(params, p.param)
+		if err != nil {
+			return nil, err
+		}
+		ints := func(values []string) (bson.A, error) {
+			out := bson.A{}
+			for _, value := range values {
+				n, err := strconv.Atoi(value)
+				if err != nil || n <= 0 {
+					return nil, badRequestf("%s must be a positive integer, got %q", p.param, value)
+				}
+				out = append(out, n)
+			}
+			return out, nil
+		}
+		if len(include) > 0 {
+			values, err := ints(include)
+			if err != nil {
+				return nil, err
+			}
+			conds = append(conds, bson.D{{Key: p.field, Value: bson.D{{Key: "$in", Value: values}}}})
+		}
+		if len(exclude) > 0 {
+			values, err := ints(exclude)
+			if err != nil {
+				return nil, err
+			}
+			excludes = append(excludes, bson.D{{Key: p.field, Value: bson.D{{Key: "$nin", Value: values}}}})
+		}
+	}
+
+	meetingConds, err := parseMeetingFilter(params)
+	if err != nil {
+		return nil, err
+	}
+	conds = append(conds, meetingConds...)
+
+	availabilityConds, err := parseAvailabilityFilter(params)
+	if err != nil {
+		return nil, err
+	}
+	excludes = append(excludes, availabilityConds...)
+
+	return composeFilter(conds, excludes, union), nil
+}
+
+/*
+ * Build the conditions on the meetings of a section. Days are
+ * matched against the days of every meeting together, times
+ * against each meeting that has one (TBA meetings never fail them).
+ * Arguments:
+ *   params : query parameters of the request
+ * Returns:
+ *   one condition per parameter given, or a badRequest
+ */
+func parseMeetingFilter(params url.Values) ([]bson.D, error) {
+	conds := []bson.D{}
+
+	days, err := singleParam(params, "days")
+	if err != nil {
+		return nil, err
+	}
+	daysMatch, err := singleParam(params, "daysmatch")
+	if err != nil {
+		return nil, err
+	}
+	if days != "" {
+		wanted := parseDays(&days)
+		if len(wanted) == 0 {
+			return nil, badRequestf("days must be letters of %s (e.g.: TR), got %q", weekDays, days)
+		}
+		in := bson.A{}
+		for _, d := range wanted {
+			in = append(in, d)
+		}
+
+		switch strings.ToLower(daysMatch) {
+		case "", "any":
+			conds = append(conds, bson.D{{Key: "meetings.days", Value: bson.D{{Key: "$in", Value: in}}}})
+		case "all", "exactly":
+			and := bson.A{}
+			for _, d := range wanted {
+				and = append(and, bson.D{{Key: "meetings.days", Value: d}})
+			}
+			if strings.ToLower(daysMatch) == "exactly" {
+				others := bson.A{}
+				for _, d := range weekDays {
+					if !strings.ContainsRune(strings.Join(wanted, ""), d) {
+						others = append(others, string(d))
+					}
+				}
+				and = append(and, bson.D{{Key: "meetings.days", Value: bson.D{{Key: "$nin", Value: others}}}})
+			}
+			conds = append(conds, bson.D{{Key: "$and", Value: and}})
+		default:
+			return nil, badRequestf("daysmatch must be any, all or exactly, got %q", daysMatch)
+		}
+	} else if daysMatch != "" {
+		return nil, badRequestf("daysmatch needs days")
+	}
+
+	// No meeting may start before earliest_start or end after latest_end
+	window := []struct {
+		param string
+		field string
+		op    string
+	}{
+		{"earliest_start", "start", "$lt"},
+		{"latest_end", "end", "$gt"},
+	}
+	for _, w := range window {
+		value, err := singleParam(params, w.param)
+		if err != nil {
+			return nil, err
+		}
+		if value == "" {
+			continue
+		}
+		minutes, ok := parseClock(value)
+		if !ok {
+			return nil, badRequestf("%s must be a time such as 10, 13:30 or 1:30pm, got %q", w.param, value)
+		}
+		conds = append(conds, bson.D{{Key: "meetings", Value: bson.D{{Key: "$not", Value: bson.D{
+			{Key: "$elemMatch", Value: bson.D{{Key: w.field, Value: bson.D{{Key: w.op, Value: minutes}}}}},
+		}}}}})
+	}
+
+	return conds, nil
+}
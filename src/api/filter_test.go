@@ -0,0 +1,137 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestParseFilterErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		wantErr string // Substring of the badRequest, none when empty
+	}{
+		{"valid", "semester=F25&category=CS&category=MTH&credits=3", ""},
+		{"missing semester", "category=CS", "semester is required"},
+		{"malformed semester", "semester=F2025x", "semester must be"},
+		{"unknown parameter", "semester=F25&colour=blue", "unknown parameter: colour"},
+		{"misspelt parameter", "semester=F25&catagory=CS", "unknown parameter: catagory"},
+		{"repeated semester", "semester=F25&semester=Sp26", "semester may only be given once"},
+		{"frontend filterType", "semester=F25&category=CS&instructor=Nye&filterType=union", ""},
+		{"filtertype alias", "semester=F25&category=CS&filtertype=U", ""},
+		{"repeated filterType", "semester=F25&filterType=union&filterType=union", "filterType may only be given once"},
+		{"repeated filtertype", "semester=F25&filtertype=union&filtertype=union", "filtertype may only be given once"},
+		{"both spellings", "semester=F25&filterType=union&filtertype=union", "filterType may only be given once"},
+		{"unknown filterType", "semester=F25&filterType=both", "filterType must be intersection or union"},
+		{"repeated earliest_start", "semester=F25&earliest_start=9&earliest_start=10", "earliest_start may only be given once"},
+		{"too many values", "semester=F25" + strings.Repeat("&category=CS", maxParamValues+1), "category may be given at most"},
+		{"empty exclusion", "semester=F25&instructor=!", "needs a value to exclude"},
+		{"negative credits", "semester=F25&credits=-1", "credits must be a non-negative number"},
+		{"non-numeric crn", "semester=F25&crn=abc", "crn must be a positive integer"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, _, err = parseFilter(params)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("parseFilter() error = %v, want none", err)
+				}
+				return
+			}
+			var invalid badRequest
+			if !errors.As(err, &invalid) {
+				t.Fatalf("parseFilter() error = %v, want a badRequest", err)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("parseFilter() error = %q, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseFilterUnknownParameterStatus(t *testing.T) {
+	params := url.Values{"semester": {"F25"}, "colour": {"blue"}}
+	_, _, err := parseFilter(params)
+
+	w := httptest.NewRecorder()
+	writeErr(w, httptest.NewRequest(http.MethodGet, "/filter?semester=F25&colour=blue", nil), err)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if !strings.Contains(w.Body.String(), `"code":"`+codeBadRequest+`"`) {
+		t.Errorf("body = %s, want the %s code", w.Body.String(), codeBadRequest)
+	}
+}
+
+func TestParseFilterEscapesRegex(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"Nye B", "Nye B"},
+		{"C++", `C\+\+`},
+		{".*", `\.\*`},
+		{"(HYB)", `\(HYB\)`},
+		{"a|b", `a\|b`},
+		{"^SLC$", `\^SLC\$`},
+		{"[A-Z]", `\[A-Z\]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			params := url.Values{"semester": {"F25"}, "instructor": {tt.value}}
+			_, filter, err := parseFilter(params)
+			if err != nil {
+				t.Fatalf("parseFilter() error = %v", err)
+			}
+			// {$and: [{instructor: {$in: [regex]}}]}
+			and := filter[0].Value.(bson.A)
+			field := and[0].(bson.D)[0]
+			in := field.Value.(bson.D)[0].Value.(bson.A)
+			regex := in[0].(bson.Regex)
+			if field.Key != "instructor" || regex.Pattern != tt.want || regex.Options != "i" {
+				t.Errorf("filter = %v, want instructor matching /%s/i", filter, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFilterType(t *testing.T) {
+	tests := []struct {
+		query     string
+		wantUnion bool
+	}{
+		{"category=CS&instructor=Nye", false},
+		{"category=CS&instructor=Nye&filterType=intersection", false},
+		{"category=CS&instructor=Nye&filterType=union", true},
+		{"category=CS&instructor=Nye&filterType=U", true},
+		{"category=CS&instructor=Nye&filtertype=union", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			params, err := url.ParseQuery("semester=F25&" + tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, filter, err := parseFilter(params)
+			if err != nil {
+				t.Fatalf("parseFilter() error = %v", err)
+			}
+			// Union nests the fields in one $or, intersection lists each
+			and := filter[0].Value.(bson.A)
+			union := len(and) == 1 && and[0].(bson.D)[0].Key == "$or"
+			if union != tt.wantUnion {
+				t.Errorf("filter = %v, want union %v", filter, tt.wantUnion)
+			}
+		})
+	}
+}
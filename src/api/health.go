@@ -0,0 +1,153 @@
+/*
+ * file: health.go
+ * Description:
+ *   Liveness and readiness endpoints. /healthz only
+ *   reports the process is serving, /readyz checks
+ *   MongoDB, that at least one term is loaded, that the
+ *   terms are migrated and that the last scrape is
+ *   recent enough to serve.
+ */
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Collection in metaDatabase holding one document per scraped term
+const scrapesCollection = "scrapes"
+
+// How long the readiness checks may take together
+const readyTimeout = 5 * time.Second
+
+type scrapeRecord struct {
+	Term       string    `bson:"term" json:"term"`
+	Courses    int       `bson:"courses" json:"courses"`
+	FinishedAt time.Time `bson:"finished_at" json:"finished_at"`
+}
+
+type check struct {
+	Ok     bool   `json:"ok"`
+	Error  string `json:"error,omitempty"`
+	Detail any    `json:"detail,omitempty"`
+}
+
+type readiness struct {
+	Status string           `json:"status"` // ready; unavailable
+	Checks map[string]check `json:"checks"`
+}
+
+/*
+ * Record a finished scrape of a term so readiness can
+ * tell how fresh the served data is
+ * Arguments:
+ *   ctx : context bounding the write
+ *   term : collection the courses were written to (e.g.: F25)
+ *   courses : number of courses written
+ */
+func RecordScrape(ctx context.Context, term string, courses int) error {
+	db := mongoClient.Database(metaDatabase).Collection(scrapesCollection)
+	_, err := db.UpdateOne(
+		ctx,
+		bson.D{{Key: "term", Value: term}},
+		bson.D{{Key: "$set", Value: scrapeRecord{
+			Term:       term,
+			Courses:    courses,
+			FinishedAt: time.Now().UTC(),
+		}}},
+		options.UpdateOne().SetUpsert(true),
+	)
+	return err
+}
+
+/*
+ * Return the most recently finished scrape
+ */
+func lastScrape(ctx context.Context) (scrapeRecord, error) {
+	db := mongoClient.Database(metaDatabase).Collection(scrapesCollection)
+	var record scrapeRecord
+	err := db.FindOne(
+		ctx,
+		bson.D{},
+		options.FindOne().SetSort(bson.D{{Key: "finished_at", Value: -1}}),
+	).Decode(&record)
+	return record, err
+}
+
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+/*
+ * Build the /readyz handler
+ * Arguments:
+ *   maxScrapeAge : oldest the last scrape may be while still ready
+ */
+func readyHandler(maxScrapeAge time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+		defer cancel()
+
+		res := readiness{Status: "ready", Checks: map[string]check{}}
+		fail := func(name string, err error, detail any) {
+			res.Status = "unavailable"
+			res.Checks[name] = check{Ok: false, Error: err.Error(), Detail: detail}
+		}
+
+		if err := mongoClient.Ping(ctx, nil); err != nil {
+			fail("mongo", err, nil)
+		} else {
+			res.Checks["mongo"] = check{Ok: true}
+		}
+
+		terms, err := listTerms(ctx)
+		if err != nil {
+			fail("terms", err, nil)
+		} else if len(terms) == 0 {
+			fail("terms", errors.New("no terms loaded"), map[string]int{"count": 0})
+		} else {
+			res.Checks["terms"] = check{Ok: true, Detail: map[string]int{"count": len(terms)}}
+		}
+
+		// Older terms answer wrongly until they are migrated
+		if done, err := migrationStatus(); !done {
+			fail("migrations", errors.New("migrations are running"), nil)
+		} else if err != nil {
+			fail("migrations", err, nil)
+		} else {
+			res.Checks["migrations"] = check{Ok: true}
+		}
+
+		record, err := lastScrape(ctx)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			fail("scrape", errors.New("no scrape has finished"), nil)
+		} else if err != nil {
+			fail("scrape", err, nil)
+		} else {
+			age := time.Since(record.FinishedAt)
+			detail := map[string]any{
+				"term":            record.Term,
+				"finished_at":     record.FinishedAt,
+				"age_seconds":     int(age.Seconds()),
+				"max_age_seconds": int(maxScrapeAge.Seconds()),
+			}
+			if age > maxScrapeAge {
+				fail("scrape", errors.New("last scrape is too old"), detail)
+			} else {
+				res.Checks["scrape"] = check{Ok: true, Detail: detail}
+			}
+		}
+
+		status := http.StatusOK
+		if res.Status != "ready" {
+			status = http.StatusServiceUnavailable
+		}
+		writeJSON(w, status, res)
+	}
+}
@@ -0,0 +1,67 @@
+/*
+ * file: indexes.go
+ * Description:
+ *   Indexes of the term collections. EnsureIndexes is
+ *   called on the staging collection before a term is
+ *   written and is safe to call again on a collection
+ *   that already has them.
+ */
+package api
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+/*
+ * Create the indexes of a term collection if they do not exist
+ * Arguments:
+ *   ctx : context bounding the index builds
+ *   term : collection the courses are written to (e.g.: F25_staging)
+ */
+func EnsureIndexes(ctx context.Context, term string) error {
+	db := mongoClient.Database(coursesDatabase).Collection(term)
+	_, err := db.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			// One document per section, InsertCourses upserts by CRN
+			Keys:    bson.D{{Key: "crn", Value: 1}},
+			Options: options.Index().SetName("crn").SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "coursecategory", Value: 1}, {Key: "courseid", Value: 1}},
+			Options: options.Index().SetName("course"),
+		},
+		// Backs /search, see search.go for what each field holds
+		{
+			Keys: bson.D{
+				{Key: "search.code", Value: "text"},
+				{Key: "search.title", Value: "text"},
+				{Key: "search.instructor", Value: "text"},
+				{Key: "search.info", Value: "text"},
+			},
+			Options: options.Index().
+				SetName("search").
+				// The tokens are already normalised, no stemming or stop words
+				SetDefaultLanguage("none").
+				SetWeights(bson.D{
+					{Key: "search.code", Value: 10},
+					{Key: "search.title", Value: 5},
+					{Key: "search.instructor", Value: 5},
+					{Key: "search.info", Value: 1},
+				}),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Backs the history of a section in analytics.go
+	_, err = mongoClient.Database(metaDatabase).Collection(snapshotsCollection).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "term", Value: 1}, {Key: "crn", Value: 1}, {Key: "at", Value: 1}},
+		Options: options.Index().SetName("history"),
+	})
+	return err
+}
@@ -0,0 +1,307 @@
+/*
+ * file: instructors.go
+ * Description:
+ *   Instructor directory built from the scraped courses.
+ *   The roster names instructors "Last F" but the spelling
+ *   drifts between terms (NYE B, Nye, B., Nye Barbara), so
+ *   every name is reduced to an id of the surname and first
+ *   initial (nye-b) and the variants sharing an id are
+ *   treated as one person.
+ */
+package api
+
+import (
+	"cmp"
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Placeholders the roster uses when nobody is assigned
+var unassignedInstructors = map[string]bool{
+	"tba":   true,
+	"staff": true,
+}
+
+type instructorSummary struct {
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`     // Most used spelling
+	Variants []string `json:"variants"` // Every spelling seen
+	Sections int      `json:"sections"`
+	Terms    []string `json:"terms"`
+	Subjects []string `json:"subjects"`
+}
+
+type instructorCourse struct {
+	Subject  string   `json:"subject"`
+	Number   int      `json:"number"`
+	Title    string   `json:"title"`
+	Sections []string `json:"sections"`
+	Crns     []int    `json:"crns"`
+}
+
+type instructorTerm struct {
+	Term    string             `json:"term"`
+	Courses []instructorCourse `json:"courses"`
+}
+
+type instructorProfile struct {
+	instructorSummary
+	Teaching        []instructorTerm `json:"teaching"`
+	MeetingPatterns []meetingPattern `json:"meeting_patterns"`
+	Buildings       []buildingCount  `json:"buildings"`
+}
+
+/*
+ * Reduce an instructor name to the id shared by its spellings
+ * Arguments:
+ *   name : scraped instructor (e.g.: Nye B) or an id (e.g.: nye-b)
+ * Returns:
+ *   the id, false for an empty name or a placeholder such as TBA
+ */
+func instructorID(name string) (string, bool) {
+	fields := strings.FieldsFunc(name, func(r rune) bool {
+		return unicode.IsSpace(r) || r == ',' || r == '.'
+	})
+	if len(fields) == 0 {
+		return "", false
+	}
+	if len(fields) == 1 && unassignedInstructors[strings.ToLower(fields[0])] {
+		return "", false
+	}
+
+	// Last word is the first name or its initial
+	surname := fields
+	initial := ""
+	if len(fields) > 1 {
+		surname = fields[:len(fields)-1]
+		initial = strings.ToLower(string([]rune(fields[len(fields)-1])[0]))
+	}
+
+	parts := []string{}
+	for _, word := range surname {
+		word = strings.ToLower(strings.ReplaceAll(word, "'", ""))
+		for _, part := range strings.FieldsFunc(word, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			parts = append(parts, part)
+		}
+	}
+	if initial != "" {
+		parts = append(parts, initial)
+	}
+	if len(parts) == 0 {
+		return "", false
+	}
+	return strings.Join(parts, "-"), true
+}
+
+/*
+ * Build the directory of every instructor teaching in terms
+ * Arguments:
+ *   ctx : context bounding the query
+ *   terms : scraped terms to read, oldest first
+ * Returns:
+ *   the instructors sorted by name
+ */
+func instructorDirectory(ctx context.Context, terms []string) ([]instructorSummary, error) {
+	if len(terms) == 0 {
+		return []instructorSummary{}, nil
+	}
+
+	db, source := termsPipeline(terms, bson.D{})
+	source = append(source, bson.D{{Key: "$group", Value: bson.D{
+		{Key: "_id", Value: bson.D{{Key: "instructor", Value: "$instructor"}, {Key: "term", Value: "$term"}}},
+		{Key: "sections", Value: bson.D{{Key: "$sum", Value: 1}}},
+		{Key: "subjects", Value: bson.D{{Key: "$addToSet", Value: "$coursecategory"}}},
+	}}})
+	cursor, err := db.Aggregate(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+	var groups []struct {
+		ID struct {
+			Instructor string `bson:"instructor"`
+			Term       string `bson:"term"`
+		} `bson:"_id"`
+		Sections int      `bson:"sections"`
+		Subjects []string `bson:"subjects"`
+	}
+	if err = cursor.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+
+	byID := map[string]*instructorSummary{}
+	spellings := map[string]map[string]int{}
+	for _, g := range groups {
+		id, ok := instructorID(g.ID.Instructor)
+		if !ok {
+			continue
+		}
+		summary, found := byID[id]
+		if !found {
+			summary = &instructorSummary{ID: id, Variants: []string{}, Terms: []string{}, Subjects: []string{}}
+			byID[id] = summary
+			spellings[id] = map[string]int{}
+		}
+		summary.Sections += g.Sections
+		spellings[id][g.ID.Instructor] += g.Sections
+		if !slices.Contains(summary.Terms, g.ID.Term) {
+			summary.Terms = append(summary.Terms, g.ID.Term)
+		}
+		for _, subject := range g.Subjects {
+			if !slices.Contains(summary.Subjects, subject) {
+				summary.Subjects = append(summary.Subjects, subject)
+			}
+		}
+	}
+
+	directory := make([]instructorSummary, 0, len(byID))
+	for id, summary := range byID {
+		for spelling, sections := range spellings[id] {
+			summary.Variants = append(summary.Variants, spelling)
+			if sections > spellings[id][summary.Name] ||
+				(sections == spellings[id][summary.Name] && spelling < summary.Name) {
+				summary.Name = spelling
+			}
+		}
+		slices.Sort(summary.Variants)
+		slices.Sort(summary.Subjects)
+		sortTerms(summary.Terms)
+		directory = append(directory, *summary)
+	}
+	slices.SortFunc(directory, func(a, b instructorSummary) int {
+		return cmp.Or(cmp.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)), cmp.Compare(a.ID, b.ID))
+	})
+	return directory, nil
+}
+
+/*
+ * GET /v1/instructors
+ * List every instructor with their sections, terms and subjects
+ */
+func instructorsHandler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	if err := checkParams(params, map[string]bool{"q": true, "terms": true}); err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	q, err := singleParam(params, "q")
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	termsParam, err := singleParam(params, "terms")
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+
+	terms, err := selectTerms(r.Context(), termsParam)
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	directory, err := instructorDirectory(r.Context(), terms)
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+
+	// q matches the id or any spelling
+	if q != "" {
+		q = strings.ToLower(q)
+		directory = slices.DeleteFunc(directory, func(s instructorSummary) bool {
+			if strings.Contains(s.ID, q) {
+				return false
+			}
+			for _, variant := range s.Variants {
+				if strings.Contains(strings.ToLower(variant), q) {
+					return false
+				}
+			}
+			return true
+		})
+	}
+	writeData(w, directory, map[string]any{"terms": terms, "count": len(directory)})
+}
+
+/*
+ * GET /v1/instructors/{id}
+ * Profile an instructor: what they taught each term, when and where.
+ * The id may also be any spelling of the name (e.g.: Nye%20B).
+ */
+func instructorHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := instructorID(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, codeNotFound, "unknown instructor: "+r.PathValue("id"))
+		return
+	}
+
+	terms, err := listTerms(r.Context())
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	directory, err := instructorDirectory(r.Context(), terms)
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	i := slices.IndexFunc(directory, func(s instructorSummary) bool { return s.ID == id })
+	if i < 0 {
+		writeError(w, http.StatusNotFound, codeNotFound, "unknown instructor: "+r.PathValue("id"))
+		return
+	}
+	profile := instructorProfile{instructorSummary: directory[i], Teaching: []instructorTerm{}}
+
+	db, source := termsPipeline(terms, bson.D{{Key: "instructor", Value: bson.D{
+		{Key: "$in", Value: profile.Variants},
+	}}})
+	source = append(source, bson.D{{Key: "$sort", Value: bson.D{
+		{Key: "termorder", Value: 1},
+		{Key: "coursecategory", Value: 1},
+		{Key: "courseid", Value: 1},
+		{Key: "section", Value: 1},
+	}}})
+	cursor, err := db.Aggregate(r.Context(), source)
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	sections := []termCourse{}
+	if err = cursor.All(r.Context(), &sections); err != nil {
+		writeErr(w, r, err)
+		return
+	}
+
+	for i := range sections {
+		s := &sections[i]
+		normaliseCourse(&s.Course)
+		if len(profile.Teaching) == 0 || profile.Teaching[len(profile.Teaching)-1].Term != s.Term {
+			profile.Teaching = append(profile.Teaching, instructorTerm{Term: s.Term, Courses: []instructorCourse{}})
+		}
+		term := &profile.Teaching[len(profile.Teaching)-1]
+		last := len(term.Courses) - 1
+		if last < 0 || term.Courses[last].Subject != s.CourseCategory || term.Courses[last].Number != s.CourseId {
+			term.Courses = append(term.Courses, instructorCourse{
+				Subject:  s.CourseCategory,
+				Number:   s.CourseId,
+				Title:    s.Title,
+				Sections: []string{},
+				Crns:     []int{},
+			})
+			last++
+		}
+		term.Courses[last].Sections = append(term.Courses[last].Sections, s.Section)
+		term.Courses[last].Crns = append(term.Courses[last].Crns, s.Crn)
+	}
+	profile.MeetingPatterns = meetingPatterns(sections)
+	profile.Buildings = buildingCounts(sections)
+
+	writeData(w, profile, nil)
+}
@@ -0,0 +1,38 @@
+package api
+
+import "testing"
+
+func TestInstructorID(t *testing.T) {
+	tests := []struct {
+		name   string
+		want   string
+		wantOK bool
+	}{
+		{"Nye B", "nye-b", true},
+		{"NYE B", "nye-b", true},
+		{"nye b", "nye-b", true},
+		{"Nye, B.", "nye-b", true},
+		{"  Nye   B ", "nye-b", true},
+		{"Nye Bill", "nye-b", true},
+		{"nye-b", "nye-b", true},
+		{"O'Brien K", "obrien-k", true},
+		{"Smith-Jones A", "smith-jones-a", true},
+		{"Van Der Berg J", "van-der-berg-j", true},
+		{"Müller M", "müller-m", true},
+		{"Kapolka", "kapolka", true},
+		{"TBA", "", false},
+		{"Staff", "", false},
+		{"STAFF", "", false},
+		{"", "", false},
+		{"   ", "", false},
+		{", .", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := instructorID(tt.name)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("instructorID(%q) = %q, %v, want %q, %v", tt.name, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,309 @@
+/*
+ * file: migrate.go
+ * Description:
+ *   Brings terms stored by older versions of the scraper
+ *   up to date. Each term records in metaDatabase how
+ *   many of termMigrations it has been through, and the
+ *   rest are run in order when the API starts, while it
+ *   already serves. Terms published by PublishTerm are
+ *   already current. A term is locked while it migrates
+ *   so a scrape finishing meanwhile cannot replace it
+ *   halfway through.
+ */
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Collection in metaDatabase holding the schema version of each term
+const schemaCollection = "schema"
+
+type termMigration struct {
+	Name string
+	Run  func(ctx context.Context, db *mongo.Collection) error
+}
+
+// Append only, a term's version is the number of these it went through
+var termMigrations = []termMigration{
+	{"one document per crn", dedupeCrns},
+	{"limit and students the right way round", swapSeatFields},
+	{"normalised meetings", backfillMeetings},
+	{"search tokens and text index", backfillSearch},
+	{"availability fields", backfillAvailability},
+}
+
+// Documents rewritten per bulk write of a migration
+const migrateBatchSize = 500
+
+// Lock of each term, held while it migrates or is replaced
+var termLocks sync.Map
+
+// Whether MigrateTerms has finished and how, reported by /readyz
+var migrationState struct {
+	sync.Mutex
+	done bool
+	err  error
+}
+
+/*
+ * Lock a term against migrations and PublishTerm
+ * Returns:
+ *   the function unlocking it
+ */
+func lockTerm(term string) func() {
+	lock, _ := termLocks.LoadOrStore(term, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	return lock.(*sync.Mutex).Unlock
+}
+
+/*
+ * Report whether MigrateTerms has finished and the error it failed with
+ */
+func migrationStatus() (bool, error) {
+	migrationState.Lock()
+	defer migrationState.Unlock()
+	return migrationState.done, migrationState.err
+}
+
+/*
+ * Record that a term is at the given schema version
+ */
+func setSchemaVersion(ctx context.Context, term string, version int) error {
+	_, err := mongoClient.Database(metaDatabase).Collection(schemaCollection).UpdateOne(
+		ctx,
+		bson.D{{Key: "_id", Value: term}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "version", Value: version}}}},
+		options.UpdateOne().SetUpsert(true),
+	)
+	return err
+}
+
+/*
+ * Run the migrations every stored term is missing and
+ * record the outcome for /readyz
+ * Arguments:
+ *   ctx : context bounding the migrations
+ */
+func MigrateTerms(ctx context.Context) error {
+	err := migrateTerms(ctx)
+	migrationState.Lock()
+	migrationState.done = true
+	migrationState.err = err
+	migrationState.Unlock()
+	return err
+}
+
+/*
+ * Migrate every stored term in turn, stopping at the first failure
+ */
+func migrateTerms(ctx context.Context) error {
+	terms, err := listTerms(ctx)
+	if err != nil {
+		return err
+	}
+	for _, term := range terms {
+		if err := migrateTerm(ctx, term); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+ * Run the migrations one term is missing, holding its lock
+ */
+func migrateTerm(ctx context.Context, term string) error {
+	unlock := lockTerm(term)
+	defer unlock()
+
+	// Read under the lock, a term published meanwhile is already current
+	var record struct {
+		Version int `bson:"version"`
+	}
+	meta := mongoClient.Database(metaDatabase).Collection(schemaCollection)
+	err := meta.FindOne(ctx, bson.D{{Key: "_id", Value: term}}).Decode(&record)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return err
+	}
+	db := mongoClient.Database(coursesDatabase).Collection(term)
+	for v := record.Version; v < len(termMigrations); v++ {
+		m := termMigrations[v]
+		slog.Info("Migrating term", "term", term, "migration", m.Name)
+		if err := m.Run(ctx, db); err != nil {
+			return fmt.Errorf("migrate %s (%s): %w", term, m.Name, err)
+		}
+		if err := setSchemaVersion(ctx, term, v+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+ * Keep only the newest document of each CRN. Scrapes used to
+ * insert the whole term again without removing the old one.
+ */
+func dedupeCrns(ctx context.Context, db *mongo.Collection) error {
+	cursor, err := db.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$crn"},
+			{Key: "ids", Value: bson.D{{Key: "$push", Value: "$_id"}}},
+			{Key: "newest", Value: bson.D{{Key: "$max", Value: "$_id"}}},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+		}}},
+		{{Key: "$match", Value: bson.D{{Key: "count", Value: bson.D{{Key: "$gt", Value: 1}}}}}},
+	})
+	if err != nil {
+		return err
+	}
+	var groups []struct {
+		IDs    []bson.ObjectID `bson:"ids"`
+		Newest bson.ObjectID   `bson:"newest"`
+	}
+	if err = cursor.All(ctx, &groups); err != nil {
+		return err
+	}
+	stale := bson.A{}
+	for _, g := range groups {
+		for _, id := range g.IDs {
+			if id != g.Newest {
+				stale = append(stale, id)
+			}
+		}
+	}
+	if len(stale) > 0 {
+		_, err = db.DeleteMany(ctx, bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: stale}}}})
+		if err != nil {
+			return err
+		}
+	}
+
+	// The crn index used to allow repeats, rebuild it unique
+	err = db.Indexes().DropOne(ctx, "crn")
+	if err != nil && !isIndexNotFound(err) {
+		return err
+	}
+	return EnsureIndexes(ctx, db.Name())
+}
+
+/*
+ * Swap limit and students back in terms scraped while the JSON tags
+ * of Course stored each under the other's name. Every term without a
+ * schema version was written by that struct, and terms published since
+ * the tags were fixed start at the latest version, so the swap always
+ * applies and runs at most once per term.
+ */
+func swapSeatFields(ctx context.Context, db *mongo.Collection) error {
+	_, err := db.UpdateMany(ctx, bson.D{}, mongo.Pipeline{
+		{{Key: "$set", Value: bson.D{
+			{Key: "limit", Value: "$students"},
+			{Key: "students", Value: "$limit"},
+			// Extra meeting rows were stored by the same struct
+			{Key: "coursechild", Value: bson.D{{Key: "$cond", Value: bson.A{
+				bson.D{{Key: "$eq", Value: bson.A{bson.D{{Key: "$type", Value: "$coursechild"}}, "object"}}},
+				bson.D{{Key: "$mergeObjects", Value: bson.A{"$coursechild", bson.D{
+					{Key: "limit", Value: "$coursechild.students"},
+					{Key: "students", Value: "$coursechild.limit"},
+				}}}},
+				"$coursechild",
+			}}}},
+		}}},
+	})
+	return err
+}
+
+/*
+ * Rewrite the courses matching filter after passing each through update
+ * Arguments:
+ *   ctx : context bounding the reads and writes
+ *   db : term collection
+ *   filter : courses to rewrite
+ *   update : changes a course in place
+ */
+func rewriteCourses(ctx context.Context, db *mongo.Collection, filter bson.D, update func(c *Course)) error {
+	cursor, err := db.Find(ctx, filter)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	models := []mongo.WriteModel{}
+	flush := func() error {
+		if len(models) == 0 {
+			return nil
+		}
+		_, err := db.BulkWrite(ctx, models)
+		models = models[:0]
+		return err
+	}
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID     bson.ObjectID `bson:"_id"`
+			Course `bson:",inline"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return err
+		}
+		update(&doc.Course)
+		models = append(models, mongo.NewReplaceOneModel().
+			SetFilter(bson.D{{Key: "_id", Value: doc.ID}}).
+			SetReplacement(doc.Course))
+		if len(models) == migrateBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+	return flush()
+}
+
+/*
+ * Store the meetings of terms scraped before they existed, the
+ * day and time filters of /filter query them
+ */
+func backfillMeetings(ctx context.Context, db *mongo.Collection) error {
+	return rewriteCourses(ctx, db, bson.D{{Key: "meetings", Value: bson.D{{Key: "$exists", Value: false}}}}, normaliseCourse)
+}
+
+/*
+ * Store the search tokens of terms scraped before /search existed
+ * and build their text index
+ */
+func backfillSearch(ctx context.Context, db *mongo.Collection) error {
+	err := rewriteCourses(ctx, db, bson.D{{Key: "search", Value: bson.D{{Key: "$exists", Value: false}}}}, func(c *Course) {
+		c.Search = buildSearch(c)
+	})
+	if err != nil {
+		return err
+	}
+	return EnsureIndexes(ctx, db.Name())
+}
+
+/*
+ * Store the open seats, fill percentage and waitlist flag of terms
+ * scraped before they existed, from the limit and students the seat
+ * fields migration put the right way round
+ */
+func backfillAvailability(ctx context.Context, db *mongo.Collection) error {
+	return rewriteCourses(ctx, db, bson.D{}, computeAvailability)
+}
+
+/*
+ * Report whether an index drop failed because the index does not exist
+ */
+func isIndexNotFound(err error) bool {
+	var cmdErr mongo.CommandError
+	return errors.As(err, &cmdErr) && cmdErr.Code == 27 // IndexNotFound
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestBackfillAvailabilityLegacyDocument(t *testing.T) {
+	tests := []struct {
+		name        string
+		limit       int
+		students    int
+		waiting     int
+		wantOpen    int32
+		wantFill    float64
+		wantWaiting bool
+	}{
+		{"open seats", 30, 12, 0, 18, 40, false},
+		{"full with a waitlist", 25, 25, 3, 0, 100, true},
+		{"over enrolled", 20, 25, 0, 0, 125, false},
+		{"no limit", 0, 0, 0, 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Stored by a scrape from before the availability fields
+			legacy, err := bson.Marshal(bson.D{
+				{Key: "_id", Value: bson.NewObjectID()},
+				{Key: "coursecategory", Value: "CS"},
+				{Key: "courseid", Value: 125},
+				{Key: "crn", Value: 40001},
+				{Key: "limit", Value: tt.limit},
+				{Key: "students", Value: tt.students},
+				{Key: "waiting", Value: tt.waiting},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var c Course
+			if err := bson.Unmarshal(legacy, &c); err != nil {
+				t.Fatal(err)
+			}
+			if c.OpenSeats != 0 || c.FillPercent != 0 || c.HasWaitlist {
+				t.Fatalf("legacy course decoded with availability %+v", c)
+			}
+
+			// What backfillAvailability writes back for the document
+			computeAvailability(&c)
+			raw, err := bson.Marshal(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			doc := bson.Raw(raw)
+			if got, ok := doc.Lookup("openseats").Int32OK(); !ok || got != tt.wantOpen {
+				t.Errorf("openseats = %v, want %d", doc.Lookup("openseats"), tt.wantOpen)
+			}
+			if got, ok := doc.Lookup("fillpercent").DoubleOK(); !ok || got != tt.wantFill {
+				t.Errorf("fillpercent = %v, want %v", doc.Lookup("fillpercent"), tt.wantFill)
+			}
+			if got, ok := doc.Lookup("haswaitlist").BooleanOK(); !ok || got != tt.wantWaiting {
+				t.Errorf("haswaitlist = %v, want %v", doc.Lookup("haswaitlist"), tt.wantWaiting)
+			}
+		})
+	}
+}
+
+func TestTermMigrationsAvailabilityAfterSeatFields(t *testing.T) {
+	// Availability is worked out from limit and students, so it must
+	// run after they are swapped the right way round
+	seats, availability := -1, -1
+	for i, m := range termMigrations {
+		switch m.Name {
+		case "limit and students the right way round":
+			seats = i
+		case "availability fields":
+			availability = i
+		}
+	}
+	if seats < 0 || availability < seats {
+		t.Errorf("seat fields migration at %d, availability at %d, want availability after", seats, availability)
+	}
+}
@@ -0,0 +1,318 @@
+/*
+ * file: page.go
+ * Description:
+ *   Sorting and pagination of course queries. A page is
+ *   selected either by offset or by an opaque cursor. The
+ *   cursor holds the sort keys of the last course returned
+ *   so the next page continues after it even when courses
+ *   are inserted in between (keyset pagination).
+ */
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// Largest page a client may ask for
+const maxLimit = 1000
+
+// Parameters selecting a page, accepted next to the filter parameters
+var pageParams = map[string]bool{
+	"limit":  true,
+	"offset": true,
+	"cursor": true,
+	"sort":   true,
+}
+
+// Expression of each sort key, missing values are replaced so
+// every course has a comparable key (TBA times sort last)
+var sortKeys = map[string][]bson.E{
+	"course": {
+		{Key: "coursecategory", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$coursecategory", ""}}}},
+		{Key: "courseid", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$courseid", 0}}}},
+		{Key: "section", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$section", ""}}}},
+	},
+	"crn": {
+		{Key: "crn", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$crn", 0}}}},
+	},
+	"start": {
+		{Key: "startminutes", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$startminutes", 24 * 60}}}},
+	},
+	"credits": {
+		{Key: "credits", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$credits", 0}}}},
+	},
+	"seats": {
+		{Key: "openseats", Value: openSeatsExpr},
+	},
+	"instructor": {
+		{Key: "instructor", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$instructor", ""}}}},
+	},
+	// Chronological, only differs from course across terms, see termsPipeline
+	"term": {
+		{Key: "termorder", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$termorder", 0}}}},
+		{Key: "coursecategory", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$coursecategory", ""}}}},
+		{Key: "courseid", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$courseid", 0}}}},
+		{Key: "section", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$section", ""}}}},
+	},
+}
+
+type page struct {
+	Limit      int // 0 returns every course
+	Offset     int
+	Sort       string // Key of sortKeys
+	Descending bool
+	After      bson.A // Sort key values of the last course of the previous page
+}
+
+type pageMeta struct {
+	Total      int64  `json:"total"`
+	Count      int    `json:"count"`
+	Limit      int    `json:"limit,omitempty"`
+	Offset     int    `json:"offset,omitempty"`
+	Sort       string `json:"sort"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+/*
+ * Validate the paging parameters
+ * Arguments:
+ *   params : query parameters of the request
+ * Returns:
+ *   the page, sorted by course code when sort is absent, or a badRequest
+ */
+func parsePage(params url.Values) (page, error) {
+	p := page{Sort: "course"}
+
+	limit, err := singleParam(params, "limit")
+	if err != nil {
+		return p, err
+	}
+	if limit != "" {
+		p.Limit, err = strconv.Atoi(limit)
+		if err != nil || p.Limit < 1 || p.Limit > maxLimit {
+			return p, badRequestf("limit must be an integer from 1 to %d, got %q", maxLimit, limit)
+		}
+	}
+
+	offset, err := singleParam(params, "offset")
+	if err != nil {
+		return p, err
+	}
+	if offset != "" {
+		p.Offset, err = strconv.Atoi(offset)
+		if err != nil || p.Offset < 0 {
+			return p, badRequestf("offset must be a non-negative integer, got %q", offset)
+		}
+	}
+
+	sort, err := singleParam(params, "sort")
+	if err != nil {
+		return p, err
+	}
+	if sort != "" {
+		p.Descending = strings.HasPrefix(sort, "-")
+		p.Sort = strings.TrimPrefix(sort, "-")
+		if _, ok := sortKeys[p.Sort]; !ok {
+			return p, badRequestf("sort must be one of course, crn, start, credits, seats, instructor, term (prefix - for descending), got %q", sort)
+		}
+	}
+
+	cursor, err := singleParam(params, "cursor")
+	if err != nil {
+		return p, err
+	}
+	if cursor != "" {
+		if p.Offset != 0 {
+			return p, badRequestf("cursor and offset cannot be used together")
+		}
+		p.After, err = decodeCursor(cursor, p)
+		if err != nil {
+			return p, err
+		}
+	}
+	return p, nil
+}
+
+/*
+ * Encode the sort key values of a course into a cursor. The sort
+ * order is part of the cursor so it cannot be reused with another.
+ */
+func encodeCursor(p page, values bson.A) (string, error) {
+	b, err := bson.Marshal(bson.D{
+		{Key: "s", Value: p.Sort},
+		{Key: "d", Value: p.Descending},
+		{Key: "v", Value: values},
+	})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+/*
+ * Decode a cursor made by encodeCursor for the same sort order
+ */
+func decodeCursor(cursor string, p page) (bson.A, error) {
+	invalid := badRequestf("cursor is invalid or was made for another sort order")
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, invalid
+	}
+	var decoded struct {
+		Sort       string `bson:"s"`
+		Descending bool   `bson:"d"`
+		Values     bson.A `bson:"v"`
+	}
+	if err = bson.Unmarshal(b, &decoded); err != nil {
+		return nil, invalid
+	}
+	// One value per sort key plus the _id tie breaker
+	if decoded.Sort != p.Sort || decoded.Descending != p.Descending ||
+		len(decoded.Values) != len(sortKeys[p.Sort])+1 {
+		return nil, invalid
+	}
+	return decoded.Values, nil
+}
+
+/*
+ * Build the match continuing after the given sort key values:
+ * (k0 > v0) or (k0 == v0 and k1 > v1) or ...
+ */
+func afterMatch(names []string, values bson.A, descending bool) bson.D {
+	op := "$gt"
+	if descending {
+		op = "$lt"
+	}
+	or := bson.A{}
+	for i := range names {
+		and := bson.D{}
+		for j := 0; j < i; j++ {
+			and = append(and, bson.E{Key: names[j], Value: values[j]})
+		}
+		and = append(and, bson.E{Key: names[i], Value: bson.D{{Key: op, Value: values[i]}}})
+		or = append(or, and)
+	}
+	return bson.D{{Key: "$or", Value: or}}
+}
+
+/*
+ * Find one page of the courses in db matching filter
+ * Arguments:
+ *   ctx : context bounding the queries
+ *   db : term collection to search
+ *   filter : course filter, e.g. from parseFilter
+ *   p : page to return
+ * Returns:
+ *   the courses and the meta data describing the page
+ */
+func queryCourses(ctx context.Context, db *mongo.Collection, filter bson.D, p page) ([]Course, pageMeta, error) {
+	raws, meta, err := queryPage(ctx, db, mongo.Pipeline{{{Key: "$match", Value: filter}}}, p)
+	if err != nil {
+		return nil, meta, err
+	}
+	courses := make([]Course, len(raws))
+	for i, raw := range raws {
+		if err = bson.Unmarshal(raw, &courses[i]); err != nil {
+			return nil, meta, err
+		}
+	}
+	return courses, meta, nil
+}
+
+/*
+ * Sort and page the documents produced by a pipeline
+ * Arguments:
+ *   ctx : context bounding the queries
+ *   db : collection the pipeline runs on
+ *   source : stages producing the matching courses
+ *   p : page to return
+ * Returns:
+ *   the documents of the page and the meta data describing it
+ */
+func queryPage(ctx context.Context, db *mongo.Collection, source mongo.Pipeline, p page) ([]bson.Raw, pageMeta, error) {
+	meta := pageMeta{Limit: p.Limit, Offset: p.Offset, Sort: p.Sort}
+	if p.Descending {
+		meta.Sort = "-" + p.Sort
+	}
+
+	counting := append(append(mongo.Pipeline{}, source...), bson.D{{Key: "$count", Value: "total"}})
+	cursor, err := db.Aggregate(ctx, counting)
+	if err != nil {
+		return nil, meta, err
+	}
+	var counted []struct {
+		Total int64 `bson:"total"`
+	}
+	if err = cursor.All(ctx, &counted); err != nil {
+		return nil, meta, err
+	}
+	// $count returns nothing when no document matches
+	if len(counted) > 0 {
+		meta.Total = counted[0].Total
+	}
+
+	// Sort keys are computed into _k0, _k1, ... and _id breaks ties
+	direction := 1
+	if p.Descending {
+		direction = -1
+	}
+	keys := bson.D{}
+	sort := bson.D{}
+	names := []string{}
+	for i, key := range sortKeys[p.Sort] {
+		name := "_k" + strconv.Itoa(i)
+		keys = append(keys, bson.E{Key: name, Value: key.Value})
+		sort = append(sort, bson.E{Key: name, Value: direction})
+		names = append(names, name)
+	}
+	sort = append(sort, bson.E{Key: "_id", Value: direction})
+	names = append(names, "_id")
+
+	pipeline := append(append(mongo.Pipeline{}, source...), bson.D{{Key: "$addFields", Value: keys}})
+	if p.After != nil {
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: afterMatch(names, p.After, p.Descending)}})
+	}
+	pipeline = append(pipeline, bson.D{{Key: "$sort", Value: sort}})
+	if p.Offset > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$skip", Value: p.Offset}})
+	}
+	if p.Limit > 0 {
+		// One extra document tells whether there is a next page
+		pipeline = append(pipeline, bson.D{{Key: "$limit", Value: p.Limit + 1}})
+	}
+
+	cursor, err = db.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, meta, err
+	}
+	var raws []bson.Raw
+	if err = cursor.All(ctx, &raws); err != nil {
+		return nil, meta, err
+	}
+
+	hasNext := p.Limit > 0 && len(raws) > p.Limit
+	if hasNext {
+		raws = raws[:p.Limit]
+	}
+	meta.Count = len(raws)
+
+	if hasNext {
+		last := raws[len(raws)-1]
+		values := bson.A{}
+		for _, name := range names {
+			values = append(values, last.Lookup(name))
+		}
+		meta.NextCursor, err = encodeCursor(p, values)
+		if err != nil {
+			return nil, meta, err
+		}
+	}
+	return raws, meta, nil
+}
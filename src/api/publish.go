@@ -0,0 +1,121 @@
+/*
+ * file: publish.go
+ * Description:
+ *   How a scrape replaces a term. The courses are written
+ *   to a staging collection, one document per CRN, which
+ *   is renamed over the term once the scrape finishes so
+ *   readers never see a term half written or twice over.
+ *   The registries and seat history are updated from the
+ *   published term, once per scrape.
+ */
+package api
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Suffix of the collection a term is scraped into, parseTerm
+// rejects it so the staging collection is never served as a term
+const stagingSuffix = "_staging"
+
+/*
+ * Return the staging collection of a term
+ */
+func stagingCollection(term string) string {
+	return term + stagingSuffix
+}
+
+/*
+ * Start a scrape of a term with an empty staging collection
+ * Arguments:
+ *   ctx : context bounding the drop and index builds
+ *   term : term being scraped (e.g.: F25)
+ */
+func StageTerm(ctx context.Context, term string) error {
+	if err := DiscardTerm(ctx, term); err != nil {
+		return err
+	}
+	return EnsureIndexes(ctx, stagingCollection(term))
+}
+
+/*
+ * Drop the staging collection of a term, leaving the served term as it was
+ */
+func DiscardTerm(ctx context.Context, term string) error {
+	return mongoClient.Database(coursesDatabase).Collection(stagingCollection(term)).Drop(ctx)
+}
+
+/*
+ * Replace a term with its staging collection in one rename
+ * Arguments:
+ *   ctx : context bounding the rename
+ *   term : term that was scraped (e.g.: F25)
+ */
+func PublishTerm(ctx context.Context, term string) error {
+	// A scrape that wrote nothing must not wipe the term
+	staged, err := mongoClient.Database(coursesDatabase).Collection(stagingCollection(term)).
+		EstimatedDocumentCount(ctx)
+	if err != nil {
+		return err
+	}
+	if staged == 0 {
+		return fmt.Errorf("publish %s: no courses were staged", term)
+	}
+
+	// Wait for a migration of the term to finish before replacing it
+	unlock := lockTerm(term)
+	defer unlock()
+	err = mongoClient.Database("admin").RunCommand(ctx, bson.D{
+		{Key: "renameCollection", Value: coursesDatabase + "." + stagingCollection(term)},
+		{Key: "to", Value: coursesDatabase + "." + term},
+		{Key: "dropTarget", Value: true},
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("publish %s: %w", term, err)
+	}
+	// A fresh scrape needs none of the migrations of older terms
+	return setSchemaVersion(ctx, term, len(termMigrations))
+}
+
+/*
+ * Update the building and subject registries and the seat
+ * history from a published term
+ * Arguments:
+ *   ctx : context bounding the reads and writes
+ *   term : term just published (e.g.: F25)
+ */
+func RecordTerm(ctx context.Context, term string) error {
+	courses, err := termCourses(ctx, mongoClient.Database(coursesDatabase).Collection(term))
+	if err != nil {
+		return err
+	}
+	if len(courses) == 0 {
+		return nil
+	}
+
+	// Flag building codes missing from the registry for review
+	unknown := map[string]int{}
+	for _, c := range courses {
+		for _, m := range c.Meetings {
+			if m.UnknownBuilding {
+				code, _ := buildingCode(m.Location)
+				unknown[code]++
+			}
+		}
+	}
+	if err := recordUnknownBuildings(ctx, term, unknown); err != nil {
+		return fmt.Errorf("record unknown buildings: %w", err)
+	}
+
+	// Extend the subject registry with the codes of the term
+	if err := recordSubjects(ctx, term, subjectCodes(courses)); err != nil {
+		return fmt.Errorf("record subjects: %w", err)
+	}
+	if err := recordSnapshots(ctx, term, courses); err != nil {
+		return fmt.Errorf("record seat snapshots: %w", err)
+	}
+	return nil
+}
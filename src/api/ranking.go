@@ -0,0 +1,312 @@
+/*
+ * file: ranking.go
+ * Description:
+ *   Preferences ranking the schedules of the generator.
+ *   Each preference given scores a schedule from 0 (ignores
+ *   it entirely) to 1 (fully meets it); the score of a
+ *   schedule is the sum, so every preference counts the same.
+ *   open_only is a requirement rather than a preference and
+ *   removes sections before any schedule is built.
+ */
+package api
+
+import (
+	"cmp"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Most schedules generated to pick the best ranked from
+const maxRankedSchedules = 10000
+
+// Parameters of the generator choosing how schedules are ranked
+var preferenceParams = map[string]bool{
+	"no_before":         true,
+	"no_after":          true,
+	"free_days":         true,
+	"minimize_gaps":     true,
+	"compact_days":      true,
+	"prefer_instructor": true,
+	"prefer_delivery":   true,
+	"open_only":         true,
+}
+
+// Lowercase delivery modes prefer_delivery=online stands for
+var onlineDeliveryModes = []string{"ol", "sol"}
+
+type preferences struct {
+	NoBefore     *int     // Minutes after midnight
+	NoAfter      *int     // Minutes after midnight
+	FreeDays     []string // Day letters
+	MinimizeGaps bool
+	CompactDays  bool
+	Instructors  []string // Instructor ids
+	Delivery     []string // Lowercase delivery modes, online matches any online section
+	OpenOnly     bool
+}
+
+/*
+ * Report whether any preference ranks the schedules
+ */
+func (p preferences) ranked() bool {
+	return p.NoBefore != nil || p.NoAfter != nil || len(p.FreeDays) > 0 || p.MinimizeGaps ||
+		p.CompactDays || len(p.Instructors) > 0 || len(p.Delivery) > 0
+}
+
+/*
+ * Read a true or false parameter, false when absent
+ */
+func boolParam(params url.Values, name string) (bool, error) {
+	value, err := singleParam(params, name)
+	if err != nil || value == "" {
+		return false, err
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, badRequestf("%s must be true or false, got %q", name, value)
+	}
+	return b, nil
+}
+
+/*
+ * Validate the preference parameters
+ * Arguments:
+ *   params : query parameters of the request
+ * Returns:
+ *   the preferences, or a badRequest
+ */
+func parsePreferences(params url.Values) (preferences, error) {
+	p := preferences{}
+
+	for _, clock := range []struct {
+		param string
+		dest  **int
+	}{
+		{"no_before", &p.NoBefore},
+		{"no_after", &p.NoAfter},
+	} {
+		value, err := singleParam(params, clock.param)
+		if err != nil {
+			return p, err
+		}
+		if value == "" {
+			continue
+		}
+		minutes, ok := parseClock(value)
+		if !ok {
+			return p, badRequestf("%s must be a time such as 10, 13:30 or 1:30pm, got %q", clock.param, value)
+		}
+		*clock.dest = &minutes
+	}
+
+	days, err := singleParam(params, "free_days")
+	if err != nil {
+		return p, err
+	}
+	if days != "" {
+		p.FreeDays = parseDays(&days)
+		if len(p.FreeDays) == 0 {
+			return p, badRequestf("free_days must be letters of %s (e.g.: F), got %q", weekDays, days)
+		}
+	}
+
+	if p.MinimizeGaps, err = boolParam(params, "minimize_gaps"); err != nil {
+		return p, err
+	}
+	if p.CompactDays, err = boolParam(params, "compact_days"); err != nil {
+		return p, err
+	}
+	if p.OpenOnly, err = boolParam(params, "open_only"); err != nil {
+		return p, err
+	}
+
+	instructors, excluded, err := splitValues(params, "prefer_instructor")
+	if err != nil {
+		return p, err
+	}
+	if len(excluded) > 0 {
+		return p, badRequestf("prefer_instructor cannot be negated with !")
+	}
+	for _, name := range instructors {
+		id, ok := instructorID(name)
+		if !ok {
+			return p, badRequestf("prefer_instructor must be an instructor name or id, got %q", name)
+		}
+		p.Instructors = append(p.Instructors, id)
+	}
+
+	delivery, excluded, err := splitValues(params, "prefer_delivery")
+	if err != nil {
+		return p, err
+	}
+	if len(excluded) > 0 {
+		return p, badRequestf("prefer_delivery cannot be negated with !")
+	}
+	for _, mode := range delivery {
+		p.Delivery = append(p.Delivery, strings.ToLower(mode))
+	}
+	return p, nil
+}
+
+/*
+ * Remove the sections a requirement rules out
+ * Returns:
+ *   the remaining sections of each course, or a not found error
+ *   naming the first course left without any
+ */
+func (p preferences) filterSections(wanted []wantedCourse, sections [][]Course) ([][]Course, error) {
+	if !p.OpenOnly {
+		return sections, nil
+	}
+	kept := make([][]Course, len(sections))
+	for i := range sections {
+		for _, c := range sections[i] {
+			if c.OpenSeats > 0 && !strings.EqualFold(c.Status, "closed") {
+				kept[i] = append(kept[i], c)
+			}
+		}
+		if len(kept[i]) == 0 {
+			return nil, notFoundf("no section of %s %d has open seats", wanted[i].Subject, wanted[i].Number)
+		}
+	}
+	return kept, nil
+}
+
+/*
+ * Return the timed meetings of a schedule on each day, sorted by start
+ */
+func meetingsByDay(s *schedule) map[string][]Meeting {
+	days := map[string][]Meeting{}
+	for _, c := range s.Sections {
+		for _, m := range c.Meetings {
+			if m.Start == nil || m.End == nil {
+				continue
+			}
+			for _, day := range m.Days {
+				days[day] = append(days[day], m)
+			}
+		}
+	}
+	for _, meetings := range days {
+		slices.SortFunc(meetings, func(a, b Meeting) int {
+			return cmp.Compare(*a.Start, *b.Start)
+		})
+	}
+	return days
+}
+
+/*
+ * Score a schedule against every preference given
+ * Returns:
+ *   the total score and the score of each preference
+ */
+func (p preferences) score(s *schedule) (float64, map[string]float64) {
+	breakdown := map[string]float64{}
+	days := meetingsByDay(s)
+
+	// Share of the timed meetings inside the window
+	timed := 0
+	early, late := 0, 0
+	for _, meetings := range days {
+		for _, m := range meetings {
+			timed++
+			if p.NoBefore != nil && *m.Start < *p.NoBefore {
+				early++
+			}
+			if p.NoAfter != nil && *m.End > *p.NoAfter {
+				late++
+			}
+		}
+	}
+	share := func(bad int) float64 {
+		if timed == 0 {
+			return 1
+		}
+		return 1 - float64(bad)/float64(timed)
+	}
+	if p.NoBefore != nil {
+		breakdown["no_before"] = share(early)
+	}
+	if p.NoAfter != nil {
+		breakdown["no_after"] = share(late)
+	}
+
+	// Share of the wanted days left free
+	if len(p.FreeDays) > 0 {
+		free := 0
+		for _, day := range p.FreeDays {
+			if len(days[day]) == 0 {
+				free++
+			}
+		}
+		breakdown["free_days"] = float64(free) / float64(len(p.FreeDays))
+	}
+
+	// 1 without waits between classes, 1/2 for an hour a week, 1/3 for two...
+	if p.MinimizeGaps {
+		gap := 0
+		for _, meetings := range days {
+			end := *meetings[0].End
+			for _, m := range meetings[1:] {
+				if *m.Start > end {
+					gap += *m.Start - end
+				}
+				end = max(end, *m.End)
+			}
+		}
+		breakdown["minimize_gaps"] = 1 / (1 + float64(gap)/60)
+	}
+
+	// One day on campus scores 1, every day of the week 0
+	if p.CompactDays {
+		breakdown["compact_days"] = 1
+		if len(days) > 1 {
+			breakdown["compact_days"] = 1 - float64(len(days)-1)/float64(len(weekDays)-1)
+		}
+	}
+
+	// Share of the sections taught by a preferred instructor
+	if len(p.Instructors) > 0 {
+		preferred := 0
+		for _, c := range s.Sections {
+			if id, ok := instructorID(c.Instructor); ok && slices.Contains(p.Instructors, id) {
+				preferred++
+			}
+		}
+		breakdown["prefer_instructor"] = float64(preferred) / float64(len(s.Sections))
+	}
+
+	// Share of the sections in a preferred delivery mode
+	if len(p.Delivery) > 0 {
+		preferred := 0
+		for _, c := range s.Sections {
+			mode := strings.ToLower(c.DeliveryMode)
+			online := c.IsOnline || slices.Contains(onlineDeliveryModes, mode)
+			if slices.Contains(p.Delivery, mode) || (online && slices.Contains(p.Delivery, "online")) {
+				preferred++
+			}
+		}
+		breakdown["prefer_delivery"] = float64(preferred) / float64(len(s.Sections))
+	}
+
+	total := 0.0
+	for _, score := range breakdown {
+		total += score
+	}
+	return total, breakdown
+}
+
+/*
+ * Score the schedules and order them best first, keeping the
+ * order of the generator between equal scores
+ */
+func (p preferences) rank(schedules []schedule) {
+	for i := range schedules {
+		schedules[i].Score, schedules[i].Breakdown = p.score(&schedules[i])
+	}
+	slices.SortStableFunc(schedules, func(a, b schedule) int {
+		return cmp.Compare(b.Score, a.Score)
+	})
+}
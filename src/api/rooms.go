@@ -0,0 +1,311 @@
+/*
+ * file: rooms.go
+ * Description:
+ *   Room use of a term worked out from the meetings of
+ *   every section. A room is a building and room (108,
+ *   108A); meetings without either (online, TBA) use no room.
+ *   Only rooms that appear in the roster are known, so a
+ *   room no section meets in never shows up as free.
+ */
+package api
+
+import (
+	"cmp"
+	"context"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Hours covered by the occupancy grid, minutes after midnight
+const (
+	gridStart = 7 * 60
+	gridEnd   = 22 * 60
+)
+
+// Grid slot used when no slot is given, in minutes
+const defaultGridSlot = 60
+
+// One meeting held in a room
+type booking struct {
+	Start  int    `json:"start"` // Minutes after midnight
+	End    int    `json:"end"`
+	Crn    int    `json:"crn"`
+	Course string `json:"course"` // CS 125 A
+}
+
+type room struct {
+	Building     string               `json:"building"`
+	BuildingName string               `json:"building_name,omitempty"` // From the building registry
+	Room         string               `json:"room"`
+	Sections     int                  `json:"sections"`
+	BusyMinutes  int                  `json:"busy_minutes"`   // Per week
+	Schedule     map[string][]booking `json:"schedule"`       // Bookings of each day, by start
+	Grid         map[string][]bool    `json:"grid,omitempty"` // Whether each slot of each day is busy
+}
+
+/*
+ * Collect the bookings of every room of a term
+ * Arguments:
+ *   ctx : context bounding the query
+ *   db : term collection
+ *   building : only rooms of this building, any when empty
+ * Returns:
+ *   the rooms sorted by building and number
+ */
+func findRooms(ctx context.Context, db *mongo.Collection, building string) ([]room, error) {
+	cursor, err := db.Find(ctx, bson.D{}, options.Find().SetProjection(bson.D{{Key: "search", Value: 0}}))
+	if err != nil {
+		return nil, err
+	}
+	courses := []Course{}
+	if err = cursor.All(ctx, &courses); err != nil {
+		return nil, err
+	}
+
+	rooms := []room{}
+	index := map[string]int{}
+	for _, c := range courses {
+		// Terms scraped before meetings existed
+		normaliseCourse(&c)
+		counted := map[int]bool{}
+		for _, m := range c.Meetings {
+			if m.Location == nil || m.Room == nil || m.Start == nil || m.End == nil {
+				continue
+			}
+			if building != "" && !strings.EqualFold(*m.Location, building) {
+				continue
+			}
+			key := *m.Location + " " + *m.Room
+			i, ok := index[key]
+			if !ok {
+				i = len(rooms)
+				index[key] = i
+				rooms = append(rooms, room{Building: *m.Location, Room: *m.Room, Schedule: map[string][]booking{}})
+				if m.BuildingName != nil {
+					rooms[i].BuildingName = *m.BuildingName
+				}
+			}
+			r := &rooms[i]
+			if !counted[i] {
+				counted[i] = true
+				r.Sections++
+			}
+			for _, day := range m.Days {
+				r.Schedule[day] = append(r.Schedule[day], booking{
+					Start:  *m.Start,
+					End:    *m.End,
+					Crn:    c.Crn,
+					Course: sectionName(&c),
+				})
+				r.BusyMinutes += *m.End - *m.Start
+			}
+		}
+	}
+
+	for _, r := range rooms {
+		for _, bookings := range r.Schedule {
+			slices.SortFunc(bookings, func(a, b booking) int {
+				return cmp.Or(cmp.Compare(a.Start, b.Start), cmp.Compare(a.Crn, b.Crn))
+			})
+		}
+	}
+	slices.SortFunc(rooms, func(a, b room) int {
+		return cmp.Or(cmp.Compare(a.Building, b.Building), compareRooms(a.Room, b.Room))
+	})
+	return rooms, nil
+}
+
+/*
+ * Order rooms by their number, then suffix (108 < 108A < 110 < GYM)
+ */
+func compareRooms(a string, b string) int {
+	na, errA := strconv.Atoi(strings.TrimRightFunc(a, unicode.IsLetter))
+	nb, errB := strconv.Atoi(strings.TrimRightFunc(b, unicode.IsLetter))
+	switch {
+	case errA == nil && errB == nil && na != nb:
+		return cmp.Compare(na, nb)
+	case errA == nil && errB != nil:
+		return -1
+	case errA != nil && errB == nil:
+		return 1
+	}
+	return cmp.Compare(a, b)
+}
+
+/*
+ * Fill in the occupancy grid of a room from its schedule
+ */
+func (r *room) fillGrid(slot int) {
+	r.Grid = map[string][]bool{}
+	for _, d := range weekDays {
+		day := string(d)
+		slots := make([]bool, (gridEnd-gridStart+slot-1)/slot)
+		for _, b := range r.Schedule[day] {
+			for i := range slots {
+				start := gridStart + i*slot
+				if b.Start < start+slot && start < b.End {
+					slots[i] = true
+				}
+			}
+		}
+		r.Grid[day] = slots
+	}
+}
+
+/*
+ * Report whether the room has no booking overlapping from-to on any of days
+ */
+func (r *room) freeDuring(days []string, from int, to int) bool {
+	for _, day := range days {
+		for _, b := range r.Schedule[day] {
+			if b.Start < to && from < b.End {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+/*
+ * Read the building parameter, any building when absent
+ */
+func buildingParam(params url.Values) (string, error) {
+	building, err := singleParam(params, "building")
+	return strings.TrimSpace(building), err
+}
+
+/*
+ * Read the slot parameter of a grid, defaultGridSlot when absent
+ */
+func parseSlot(params url.Values) (int, error) {
+	value, err := singleParam(params, "slot")
+	if err != nil || value == "" {
+		return defaultGridSlot, err
+	}
+	slot, err := strconv.Atoi(value)
+	if err != nil || !slices.Contains([]int{15, 30, 60}, slot) {
+		return 0, badRequestf("slot must be 15, 30 or 60 minutes, got %q", value)
+	}
+	return slot, nil
+}
+
+/*
+ * GET /v1/terms/{term}/rooms
+ * List the rooms of a term with their weekly bookings and occupancy grid
+ */
+func roomsHandler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	if err := checkParams(params, map[string]bool{"building": true, "slot": true}); err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	building, err := buildingParam(params)
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	slot, err := parseSlot(params)
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+
+	db, err := termCollection(r.Context(), r.PathValue("term"))
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	rooms, err := findRooms(r.Context(), db, building)
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	for i := range rooms {
+		rooms[i].fillGrid(slot)
+	}
+	writeData(w, rooms, map[string]any{
+		"count":      len(rooms),
+		"grid_start": gridStart,
+		"grid_end":   gridEnd,
+		"slot":       slot,
+	})
+}
+
+/*
+ * GET /v1/terms/{term}/rooms/free?days=&from=&to=
+ * List the rooms with no meeting between from and to on any of days
+ */
+func freeRoomsHandler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	err := checkParams(params, map[string]bool{"building": true, "days": true, "from": true, "to": true})
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	building, err := buildingParam(params)
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+
+	daysParam, err := singleParam(params, "days")
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	days := parseDays(&daysParam)
+	if len(days) == 0 {
+		writeErr(w, r, badRequestf("days must be letters of %s (e.g.: T), got %q", weekDays, daysParam))
+		return
+	}
+	window := [2]int{}
+	for i, name := range []string{"from", "to"} {
+		value, err := singleParam(params, name)
+		if err != nil {
+			writeErr(w, r, err)
+			return
+		}
+		minutes, ok := parseClock(value)
+		if !ok {
+			writeErr(w, r, badRequestf("%s must be a time such as 13, 13:30 or 1:30pm, got %q", name, value))
+			return
+		}
+		window[i] = minutes
+	}
+	if window[0] >= window[1] {
+		writeErr(w, r, badRequestf("from must be before to"))
+		return
+	}
+
+	db, err := termCollection(r.Context(), r.PathValue("term"))
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	rooms, err := findRooms(r.Context(), db, building)
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+
+	free := []room{}
+	for i := range rooms {
+		if rooms[i].freeDuring(days, window[0], window[1]) {
+			free = append(free, rooms[i])
+		}
+	}
+	writeData(w, free, map[string]any{
+		"count": len(free),
+		"days":  days,
+		"from":  window[0],
+		"to":    window[1],
+	})
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCompareRooms(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"108", "108", 0},
+		{"9", "10", -1},
+		{"108", "108A", -1},
+		{"108A", "108B", -1},
+		{"108A", "110", -1},
+		{"110", "GYM", -1},
+		{"GYM", "POOL", -1},
+		{"", "GYM", -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+"/"+tt.b, func(t *testing.T) {
+			if got := compareRooms(tt.a, tt.b); got != tt.want {
+				t.Errorf("compareRooms(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+			}
+			if got := compareRooms(tt.b, tt.a); got != -tt.want {
+				t.Errorf("compareRooms(%q, %q) = %d, want %d", tt.b, tt.a, got, -tt.want)
+			}
+		})
+	}
+
+	rooms := []string{"GYM", "110", "108B", "9", "POOL", "108", "10", "108A"}
+	slices.SortFunc(rooms, compareRooms)
+	want := []string{"9", "10", "108", "108A", "108B", "110", "GYM", "POOL"}
+	if !slices.Equal(rooms, want) {
+		t.Errorf("sorted rooms = %v, want %v", rooms, want)
+	}
+}
+
+func TestFillGrid(t *testing.T) {
+	tests := []struct {
+		name      string
+		slot      int
+		bookings  []booking // On Monday
+		wantSlots int
+		wantBusy  []int // Busy slots of Monday
+	}{
+		{"empty", 60, nil, 15, []int{}},
+		{"within one hour", 60, []booking{{Start: 540, End: 590}}, 15, []int{2}},
+		{"across an hour", 60, []booking{{Start: 590, End: 640}}, 15, []int{2, 3}},
+		{"ending on the hour", 60, []booking{{Start: 540, End: 600}}, 15, []int{2}},
+		{"half hour slots", 30, []booking{{Start: 540, End: 590}}, 30, []int{4, 5}},
+		{"several bookings", 60, []booking{{Start: 480, End: 530}, {Start: 780, End: 890}}, 15, []int{1, 6, 7}},
+		{"before the grid", 60, []booking{{Start: 360, End: 450}}, 15, []int{0}},
+		{"after the grid", 60, []booking{{Start: 1320, End: 1380}}, 15, []int{}},
+		{"partial last slot", 40, []booking{{Start: 1300, End: 1315}}, 23, []int{22}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := room{Schedule: map[string][]booking{"M": tt.bookings}}
+			r.fillGrid(tt.slot)
+
+			for _, d := range weekDays {
+				slots, ok := r.Grid[string(d)]
+				if !ok || len(slots) != tt.wantSlots {
+					t.Fatalf("day %c has %d slots, want %d", d, len(slots), tt.wantSlots)
+				}
+				busy := []int{}
+				for i, b := range slots {
+					if b {
+						busy = append(busy, i)
+					}
+				}
+				want := []int{}
+				if d == 'M' {
+					want = tt.wantBusy
+				}
+				if !slices.Equal(busy, want) {
+					t.Errorf("busy slots of %c = %v, want %v", d, busy, want)
+				}
+			}
+		})
+	}
+}
@@ -2,23 +2,35 @@ package api
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"net/http/pprof"
 	"encoding/json"
-	"sync"
-	"strconv"
+	"time"
 
-	"wilkesu-scrapy/db"
+	"wilkesu-scrapy/config"
+	"wilkesu-scrapy/metrics"
 
-	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
 
 	"github.com/rs/cors"
 )
 
 var mongoClient *mongo.Client
 
+// Each term is a collection in coursesDatabase, anything
+// else the API keeps lives in metaDatabase
+const (
+	coursesDatabase = "Courses"
+	metaDatabase    = "Meta"
+)
+
+// How long in-flight requests get to finish on shutdown
+const shutdownTimeout = 10 * time.Second
+
 type Course struct {
 	DeliveryMode   string    `json:"delivery_mode,omitempty"` // F2F; HYB; null etc.
 	CourseCategory string    `json:"course_category,omitempty"` // CS; MTH; ENG etc.
@@ -31,17 +43,25 @@ type Course struct {
 	StartTime      *string   `json:"start_time,omitempty"`// 0100; 0800; 0430; null etc.
 	EndTime        *string   `json:"end_time,omitempty"` // 0100; 0800; 0430; null etc.
 	EndTimeAMPM    *string   `json:"end_time_ampm,omitempty"` // AM; PM; null.
+	StartMinutes   *int      `json:"start_minutes,omitempty"` // 540 for 9:00 AM; null for TBA. Set on insert.
+	EndMinutes     *int      `json:"end_minutes,omitempty"` // 830 for 1:50 PM; null for TBA. Set on insert.
+	Meetings       []Meeting `json:"meetings,omitempty"` // Every meeting including extra time rows. Set on insert.
 	Location       *string   `json:"location,omitempty"`// SLC; BREIS; null etc.
 	RoomNum        *int      `json:"room_num,omitempty"` // 108, 409, any number, null etc.
+	Room           *string   `json:"room,omitempty"` // 108; 108A; GYM; null etc.
 	Instructor     string    `json:"instructor,omitempty"` // Nye B; Simpson H; Kapolka M etc.
 	Status         string    `json:"status,omitempty"` // Open; Nearly; Closed.
-	Limit          int       `json:"students,omitempty"` // Limit to number of students
-	Students       int       `json:"limit,omitempty"`
+	Limit          int       `json:"limit,omitempty"` // Limit to number of students
+	Students       int       `json:"students,omitempty"`
 	Waiting        int       `json:"waiting,omitempty"`
+	OpenSeats      int       `json:"open_seats"` // Limit - Students, never below 0. Set on insert.
+	FillPercent    float64   `json:"fill_percent"` // Students / Limit * 100; 0 without a limit. Set on insert.
+	HasWaitlist    bool      `json:"has_waitlist"` // Waiting > 0. Set on insert.
 	Info           *string   `json:"info,omitempty"` // HONORS STUDENTS ONLY; CROSS-LISTED WITH IM 350 A etc.
 	IsOnline       bool      `json:"is_online,omitempty"` // This is for full online classes (OL) not SOL or HYB
 	IsCourseChild  bool      `json:"is_course_child,omitempty"` // If this course refers to a pervious course
 	CourseChild    *Course
+	Search         *searchFields `json:"-"` // Tokens backing /search. Set on insert.
 }
 
 func responseHandler(w http.ResponseWriter, r *http.Request) {
@@ -51,99 +71,83 @@ func responseHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get responses
-	params := r.URL.Query()
-	semester := params["semester"][0]
-	deliverymode := params["deliverymode"]
-	category := params["category"]
-	credits := params["credits"]
-	location := params["location"]
-	instructor := params["instructor"]
-	status := params["status"]
-	crn := params["crn"]
-
-	filterOpts := []string{
-		"deliverymode",
-		"coursecategory",
-		"location",
-		"instructor",
-		"status",
-	}
-
-	receivedParams := [][]string {
-		deliverymode,
-			category,
-			location,
-			instructor,
-			status,
-		}
-
-	fmt.Println(receivedParams)
-
-	// Handle string parameters
-	filter := bson.D{}
-	for i, _  := range receivedParams {
-		if len(receivedParams[i]) > 0 {
-			filter = append(filter, bson.E{
-				filterOpts[i],
-				bson.D{
-					{"$regex", receivedParams[i][0]},
-					{"$options", "i"},
-				},
-			})
-		}
-	}
-
-	// Handle integer parameters
-	if len(credits) > 0 {
-		creditsint, _ := strconv.Atoi(credits[0])
-		filter = append(filter, bson.E{
-			"credits", creditsint,
-		})
+	// Validate parameters and build the filter
+	semester, filter, err := parseFilter(r.URL.Query())
+	if err != nil {
+		writeErr(w, r, err)
+		return
 	}
 
-	if len(crn) > 0 {
-		crnint, _ := strconv.Atoi(crn[0])
-		filter = append(filter, bson.E{
-			"crn", crnint,
-		})
-	}
+	slog.Debug("Filter request", "semester", semester, "filter", filter)
 
 	// Set up db connection
-	db := mongoClient.Database("Courses").Collection(semester)
+	db, err := termCollection(r.Context(), semester)
+	if errors.Is(err, errUnknownTerm) {
+		writeError(w, http.StatusNotFound, codeNotFound, "unknown semester: "+semester)
+		return
+	} else if err != nil {
+		writeErr(w, r, err)
+		return
+	}
 
-	response, err := db.Find(context.TODO(), filter)
+	p, err := parsePage(r.URL.Query())
 	if err != nil {
-		panic(err)
+		writeErr(w, r, err)
+		return
 	}
 
-	var results []Course
-	if err = response.All(context.TODO(), &results); err != nil {
-		panic(err)
+	results, meta, err := queryCourses(r.Context(), db, filter, p)
+	if err != nil {
+		writeErr(w, r, err)
+		return
 	}
-	b, _ := json.Marshal(results)
-	fmt.Fprintf(w, string(b))
+	writeData(w, results, meta)
 }
 
 /*
- * Insert a course into the admin database in collection named semester
+ * Write a batch of courses into the staging collection of semester,
+ * replacing any course already written with the same CRN
  * Arguments:
- *   courseData : JSON byte array of course data
+ *   ctx : context bounding the write
+ *   courseData : JSON byte arrays of course data
  *   semester : string representation of the semester (e.g.: Sp2025, F2025, Sp1456, etc ...)
  */
-func InsertCourse(courseData []byte, semester string) {
-	db := mongoClient.Database("Courses").Collection(semester)
+func InsertCourses(ctx context.Context, courseData [][]byte, semester string) error {
+	if len(courseData) == 0 {
+		return nil
+	}
+	db := mongoClient.Database(coursesDatabase).Collection(stagingCollection(semester))
 
-	var course Course
-	json.Unmarshal(courseData, &course)
-	_, err := db.InsertOne(
-		context.TODO(),
-		course,
-	)
+	courses := make([]Course, len(courseData))
+	for i := range courseData {
+		if err := json.Unmarshal(courseData[i], &courses[i]); err != nil {
+			return err
+		}
+		normaliseCourse(&courses[i])
+		computeAvailability(&courses[i])
+		courses[i].Search = buildSearch(&courses[i])
+	}
 
-	if err != nil {
-		panic(err)
+	// Chunks reparsed after a bad chunk send their courses again
+	models := make([]mongo.WriteModel, len(courses))
+	for i := range courses {
+		models[i] = mongo.NewReplaceOneModel().
+			SetFilter(bson.D{{Key: "crn", Value: courses[i].Crn}}).
+			SetReplacement(courses[i]).
+			SetUpsert(true)
+	}
+	_, err := db.BulkWrite(ctx, models)
+	return err
+}
 
+/*
+ * Write v as a JSON response with the given status code
+ */
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("Failed to write response", "err", err)
 	}
 }
 
@@ -151,47 +155,95 @@ func testResponse(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "Hello there!\n")
 }
 
-func Serve() {
-	var wg sync.WaitGroup
-	wg.Add(1)
+/*
+ * Set the MongoDB client used by the handlers and inserts.
+ * Must be called before Serve or StageTerm.
+ */
+func SetClient(client *mongo.Client) {
+	mongoClient = client
+}
+
+/*
+ * Serve the API on :8080 until ctx is cancelled, then stop
+ * accepting connections and drain in-flight requests
+ */
+func Serve(ctx context.Context) error {
+	slog.Info("Initializing endpoints")
+	cfg := config.LoadConfig()
+
+	// Include CORS headers
+	c := cors.New(cors.Options{
+		AllowedOrigins: []string{
+			"http://localhost:5173",
+		},
+		AllowedMethods: []string{
+			"GET",
+			"POST",
+		},
+		AllowedHeaders: []string{
+			"*",
+		},
+		AllowCredentials: true,
+	})
+
+	// Build server options
+	mux := http.NewServeMux()
+	mux.HandleFunc("/filter", responseHandler)
+	mux.HandleFunc("GET /search", searchHandler)
+	mux.HandleFunc("/test", testResponse)
+	mux.HandleFunc("/healthz", healthHandler)
+	mux.HandleFunc("/readyz", readyHandler(cfg.MaxScrapeAge()))
+	mux.Handle("/metrics", metrics.Handler())
+	registerV1(mux, cfg)
+
+	// Profiling endpoints for diagnosing slow scrapes and queries
+	if cfg.EnablePprof {
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+		slog.Info("Serving pprof endpoints", "path", "/debug/pprof/")
+	}
 
-	// Conxonect to MongoDB instance
-	mongoClient = db.Connect()
+	handler := c.Handler(jsonMethodErrors(metrics.InstrumentMux(mux)))
+	server := http.Server{
+		Addr: ":8080",
+		Handler: handler,
+	}
 
-	log.Println("Initializing endpoints ...")
+	// Serve on :8080
+	serveErr := make(chan error, 1)
 	go func() {
-		defer wg.Done()
-
-		// Include CORS headers
-		c := cors.New(cors.Options{
-			AllowedOrigins: []string{
-				"http://localhost:5173",
-			},
-			AllowedMethods: []string{
-				"GET",
-			},
-			AllowedHeaders: []string{
-				"*",
-			},
-			AllowCredentials: true,
-		})
-
-		// Build server options
-		mux := http.NewServeMux()
-		mux.HandleFunc("/filter", responseHandler)
-		mux.HandleFunc("/test", testResponse)
-
-		handler := c.Handler(mux)
-		server := http.Server{
-			Addr: ":8080",
-			Handler: handler,
-		}
+		slog.Info("Endpoint being served", "addr", server.Addr)
+		serveErr <- server.ListenAndServe()
+	}()
 
-		// Serve on :8080
-		if err := server.ListenAndServe(); err != nil {
-			panic(err)
+	// Terms stored by older scrapes are brought up to date while
+	// serving, /readyz answers 503 until they are
+	migrated := make(chan struct{})
+	go func() {
+		defer close(migrated)
+		if err := MigrateTerms(ctx); err != nil {
+			slog.Error("Failed to migrate terms", "err", err)
 		}
-		log.Println("Endpoint being served on localhost:8080")
 	}()
-	wg.Wait()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("Shutting down endpoints")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	err := server.Shutdown(shutdownCtx)
+	// Migrations stop with ctx, wait so they finish before Mongo disconnects
+	<-migrated
+	if err != nil {
+		return err
+	}
+	slog.Info("Endpoints shut down")
+	return nil
 }
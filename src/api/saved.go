@@ -0,0 +1,232 @@
+/*
+ * file: saved.go
+ * Description:
+ *   Saved schedules a student can share, e.g. with an
+ *   advisor, without an account. A schedule is the CRNs
+ *   chosen in a term; its share id is derived from them,
+ *   so saving the same schedule twice returns the same
+ *   id. Reading it back looks the sections up again so
+ *   seats and status are live.
+ */
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base32"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// Collection in metaDatabase holding the saved schedules
+const schedulesCollection = "schedules"
+
+// Length of a share id, lengthened only if two schedules collide
+const shareIDLength = 8
+
+var shareEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+type savedSchedule struct {
+	ID        string    `bson:"_id" json:"id"`
+	Term      string    `bson:"term" json:"term"`
+	Crns      []int     `bson:"crns" json:"crns"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
+
+type savedScheduleView struct {
+	savedSchedule
+	Credits  float32  `json:"credits"`
+	Missing  []int    `json:"missing"` // CRNs no longer in the term
+	Sections []Course `json:"sections"`
+}
+
+type saveRequest struct {
+	Term string `json:"term"`
+	Crns []int  `json:"crns"`
+}
+
+/*
+ * Derive the full share id of a schedule, prefixes of it are used
+ */
+func shareID(term string, crns []int) string {
+	parts := []string{term}
+	for _, crn := range crns {
+		parts = append(parts, strconv.Itoa(crn))
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, ":")))
+	return shareEncoding.EncodeToString(sum[:])
+}
+
+/*
+ * Store a schedule under the shortest free prefix of its share id
+ * Arguments:
+ *   ctx : context bounding the writes
+ *   term : term of the schedule
+ *   crns : CRNs of the schedule, sorted and without repeats
+ * Returns:
+ *   the saved schedule and whether it was already saved
+ */
+func saveSchedule(ctx context.Context, term string, crns []int) (savedSchedule, bool, error) {
+	db := mongoClient.Database(metaDatabase).Collection(schedulesCollection)
+	full := shareID(term, crns)
+	for n := shareIDLength; n <= len(full); n += 4 {
+		saved := savedSchedule{ID: full[:n], Term: term, Crns: crns, CreatedAt: time.Now().UTC()}
+		_, err := db.InsertOne(ctx, saved)
+		if err == nil {
+			return saved, false, nil
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return saved, false, err
+		}
+
+		// Either this schedule was saved before or another one has the id
+		var existing savedSchedule
+		if err = db.FindOne(ctx, bson.D{{Key: "_id", Value: saved.ID}}).Decode(&existing); err != nil {
+			return saved, false, err
+		}
+		if existing.Term == term && slices.Equal(existing.Crns, crns) {
+			return existing, true, nil
+		}
+	}
+	return savedSchedule{}, false, errors.New("no free share id for schedule")
+}
+
+/*
+ * POST /v1/schedules
+ * Save a schedule from a JSON body {"term": "F25", "crns": [...]}
+ */
+func saveScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	var req saveRequest
+	if err := readJSON(w, r, &req); err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	if _, _, ok := parseTerm(req.Term); !ok {
+		writeErr(w, r, badRequestf("term must be F or Sp followed by a year (e.g.: F25), got %q", req.Term))
+		return
+	}
+	if len(req.Crns) == 0 || len(req.Crns) > maxParamValues {
+		writeErr(w, r, badRequestf("crns must hold from 1 to %d CRNs", maxParamValues))
+		return
+	}
+	crns := slices.Clone(req.Crns)
+	slices.Sort(crns)
+	crns = slices.Compact(crns)
+	if crns[0] <= 0 {
+		writeErr(w, r, badRequestf("crns must be positive integers"))
+		return
+	}
+
+	db, err := termCollection(r.Context(), req.Term)
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	_, missing, err := findCrns(r.Context(), db, crns)
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	if len(missing) > 0 {
+		writeErr(w, r, badRequestf("crns not offered in %s: %s", req.Term, joinInts(missing)))
+		return
+	}
+
+	saved, existed, err := saveSchedule(r.Context(), req.Term, crns)
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	status := http.StatusCreated
+	if existed {
+		status = http.StatusOK
+	}
+	writeJSON(w, status, envelope{Data: saved})
+}
+
+/*
+ * GET /v1/schedules/{id}
+ * Get a saved schedule with the current state of its sections
+ */
+func savedScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	var saved savedSchedule
+	err := mongoClient.Database(metaDatabase).Collection(schedulesCollection).
+		FindOne(r.Context(), bson.D{{Key: "_id", Value: r.PathValue("id")}}).Decode(&saved)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		writeError(w, http.StatusNotFound, codeNotFound, "unknown schedule: "+r.PathValue("id"))
+		return
+	} else if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+
+	// The term may have been dropped since, its sections are then all missing
+	view := savedScheduleView{savedSchedule: saved, Missing: []int{}, Sections: []Course{}}
+	db := mongoClient.Database(coursesDatabase).Collection(saved.Term)
+	view.Sections, view.Missing, err = findCrns(r.Context(), db, saved.Crns)
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	for _, c := range view.Sections {
+		view.Credits += c.Credits
+	}
+	writeData(w, view, nil)
+}
+
+/*
+ * Find the sections of a term with the given CRNs
+ * Arguments:
+ *   ctx : context bounding the query
+ *   db : term collection
+ *   crns : CRNs to look up
+ * Returns:
+ *   the sections found in the order of crns, normalised, and the CRNs not found
+ */
+func findCrns(ctx context.Context, db *mongo.Collection, crns []int) ([]Course, []int, error) {
+	cursor, err := db.Find(ctx, bson.D{{Key: "crn", Value: bson.D{{Key: "$in", Value: crns}}}})
+	if err != nil {
+		return nil, nil, err
+	}
+	found := []Course{}
+	if err = cursor.All(ctx, &found); err != nil {
+		return nil, nil, err
+	}
+	byCrn := map[int]Course{}
+	for _, c := range found {
+		byCrn[c.Crn] = c
+	}
+
+	sections := []Course{}
+	missing := []int{}
+	for _, crn := range crns {
+		c, ok := byCrn[crn]
+		if !ok {
+			missing = append(missing, crn)
+			continue
+		}
+		// Terms scraped before meetings and availability existed
+		normaliseCourse(&c)
+		computeAvailability(&c)
+		sections = append(sections, c)
+	}
+	return sections, missing, nil
+}
+
+/*
+ * Join integers with commas for messages
+ */
+func joinInts(values []int) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = strconv.Itoa(v)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,345 @@
+/*
+ * file: schedules.go
+ * Description:
+ *   Builds timetables out of the courses a student wants.
+ *   Every combination of one section per course whose
+ *   meetings never overlap is a schedule. Combinations
+ *   are searched depth first, dropping a partial schedule
+ *   as soon as a section conflicts with it.
+ */
+package api
+
+import (
+	"cmp"
+	"context"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Most courses one schedule may hold
+const maxScheduleCourses = 10
+
+// Schedules returned when no limit is given
+const defaultScheduleLimit = 100
+
+// Sections tried before the search gives up, bounding the work
+// done for requests whose sections almost all conflict
+const maxScheduleSteps = 1000000
+
+// CS125, CS 125 or MTH-111, optionally pinned to sections: CS125:A,B
+var courseParam = regexp.MustCompile(`^([A-Za-z]+)[\s-]*(\d+)(?::([A-Za-z0-9]+(?:,[A-Za-z0-9]+)*))?$`)
+
+// A course wanted in the schedule
+type wantedCourse struct {
+	Subject  string   `json:"subject"`
+	Number   int      `json:"number"`
+	Sections []string `json:"sections,omitempty"` // Only these sections, any when empty
+}
+
+type schedule struct {
+	Crns      []int              `json:"crns"`
+	Credits   float32            `json:"credits"`
+	Score     float64            `json:"score"`               // Sum of the breakdown, see ranking.go
+	Breakdown map[string]float64 `json:"breakdown,omitempty"` // Score of each preference given
+	Walking   []walkWarning      `json:"walking"`             // Tight transitions, see walking.go
+	Sections  []Course           `json:"sections"`
+}
+
+/*
+ * Report whether two meetings take place at the same time
+ * on some day, meetings with a TBA time never overlap
+ */
+func meetingsOverlap(a Meeting, b Meeting) bool {
+	if a.Start == nil || a.End == nil || b.Start == nil || b.End == nil {
+		return false
+	}
+	if *a.Start >= *b.End || *b.Start >= *a.End {
+		return false
+	}
+	for _, day := range a.Days {
+		if slices.Contains(b.Days, day) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+ * Report whether any meeting of a overlaps any meeting of b
+ */
+func sectionsConflict(a *Course, b *Course) bool {
+	for _, ma := range a.Meetings {
+		for _, mb := range b.Meetings {
+			if meetingsOverlap(ma, mb) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+/*
+ * Parse the repeated course parameter
+ * Returns:
+ *   the wanted courses in the order given, or a badRequest
+ */
+func parseWantedCourses(params url.Values) ([]wantedCourse, error) {
+	values := params["course"]
+	if len(values) == 0 {
+		return nil, badRequestf("course is required (e.g.: course=CS125&course=MTH111:A)")
+	}
+	if len(values) > maxScheduleCourses {
+		return nil, badRequestf("course may be given at most %d times", maxScheduleCourses)
+	}
+
+	wanted := []wantedCourse{}
+	for _, value := range values {
+		match := courseParam.FindStringSubmatch(strings.TrimSpace(value))
+		if match == nil {
+			return nil, badRequestf("course must be a subject and number, optionally with sections (e.g.: CS125 or CS125:A,B), got %q", value)
+		}
+		number, err := strconv.Atoi(match[2])
+		if err != nil {
+			return nil, badRequestf("course number is too large, got %q", value)
+		}
+		c := wantedCourse{Subject: strings.ToUpper(match[1]), Number: number}
+		if match[3] != "" {
+			c.Sections = strings.Split(strings.ToUpper(match[3]), ",")
+		}
+		for _, other := range wanted {
+			if other.Subject == c.Subject && other.Number == c.Number {
+				return nil, badRequestf("course %s %d is given twice", c.Subject, c.Number)
+			}
+		}
+		wanted = append(wanted, c)
+	}
+	return wanted, nil
+}
+
+/*
+ * Find the sections of each wanted course
+ * Arguments:
+ *   ctx : context bounding the query
+ *   db : term collection
+ *   wanted : courses to look up
+ * Returns:
+ *   the sections of each course in the order of wanted, normalised,
+ *   or a not found error naming the first course without sections
+ */
+func findSections(ctx context.Context, db *mongo.Collection, wanted []wantedCourse) ([][]Course, error) {
+	or := bson.A{}
+	for _, c := range wanted {
+		or = append(or, bson.D{{Key: "coursecategory", Value: c.Subject}, {Key: "courseid", Value: c.Number}})
+	}
+	cursor, err := db.Find(ctx, bson.D{{Key: "$or", Value: or}},
+		options.Find().SetSort(bson.D{{Key: "section", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	found := []Course{}
+	if err = cursor.All(ctx, &found); err != nil {
+		return nil, err
+	}
+
+	sections := make([][]Course, len(wanted))
+	for _, course := range found {
+		// Terms scraped before meetings existed are normalised here
+		normaliseCourse(&course)
+		computeAvailability(&course)
+		for i, c := range wanted {
+			if course.CourseCategory != c.Subject || course.CourseId != c.Number {
+				continue
+			}
+			if len(c.Sections) == 0 || slices.Contains(c.Sections, strings.ToUpper(course.Section)) {
+				sections[i] = append(sections[i], course)
+			}
+		}
+	}
+	for i, c := range wanted {
+		if len(sections[i]) == 0 {
+			if len(c.Sections) > 0 {
+				return nil, notFoundf("no section %s of %s %d", strings.Join(c.Sections, ", "), c.Subject, c.Number)
+			}
+			return nil, notFoundf("unknown course: %s %d", c.Subject, c.Number)
+		}
+	}
+	return sections, nil
+}
+
+/*
+ * Generate the conflict free combinations of one section per course
+ * Arguments:
+ *   sections : the candidate sections of each course
+ *   limit : most schedules to return
+ * Returns:
+ *   the schedules, and whether the search stopped early
+ *   because it hit limit or maxScheduleSteps
+ */
+func generateSchedules(sections [][]Course, limit int) ([]schedule, bool) {
+	// Courses with the fewest sections first prune the most
+	order := make([]int, len(sections))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		return cmp.Compare(len(sections[a]), len(sections[b]))
+	})
+
+	schedules := []schedule{}
+	chosen := make([]*Course, len(sections))
+	steps := 0
+	truncated := false
+
+	var search func(depth int)
+	search = func(depth int) {
+		if truncated {
+			return
+		}
+		if depth == len(order) {
+			if len(schedules) == limit {
+				truncated = true
+				return
+			}
+			s := schedule{Crns: []int{}, Sections: []Course{}}
+			// Sections in the order the courses were asked for
+			for _, c := range chosen {
+				s.Crns = append(s.Crns, c.Crn)
+				s.Credits += c.Credits
+				s.Sections = append(s.Sections, *c)
+			}
+			schedules = append(schedules, s)
+			return
+		}
+
+		i := order[depth]
+		for j := range sections[i] {
+			steps++
+			if steps > maxScheduleSteps {
+				truncated = true
+				return
+			}
+			candidate := &sections[i][j]
+			conflict := false
+			for _, k := range order[:depth] {
+				if sectionsConflict(candidate, chosen[k]) {
+					conflict = true
+					break
+				}
+			}
+			if conflict {
+				continue
+			}
+			chosen[i] = candidate
+			search(depth + 1)
+			if truncated {
+				return
+			}
+		}
+	}
+	search(0)
+	return schedules, truncated
+}
+
+/*
+ * GET /v1/schedules/generate?term=&course=
+ * List every conflict free schedule of the wanted courses in a term,
+ * best first when preferences are given
+ */
+func generateSchedulesHandler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	err := checkParams(params, map[string]bool{"term": true, "course": true, "limit": true}, preferenceParams)
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+
+	term, err := singleParam(params, "term")
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	if term == "" {
+		writeErr(w, r, badRequestf("term is required (e.g.: term=F25)"))
+		return
+	}
+	wanted, err := parseWantedCourses(params)
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	limit := defaultScheduleLimit
+	limitParam, err := singleParam(params, "limit")
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	if limitParam != "" {
+		limit, err = strconv.Atoi(limitParam)
+		if err != nil || limit < 1 || limit > maxLimit {
+			writeErr(w, r, badRequestf("limit must be an integer from 1 to %d, got %q", maxLimit, limitParam))
+			return
+		}
+	}
+
+	prefs, err := parsePreferences(params)
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+
+	db, err := termCollection(r.Context(), term)
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	sections, err := findSections(r.Context(), db, wanted)
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+
+	sections, err = prefs.filterSections(wanted, sections)
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+
+	// Ranking needs many more schedules than are returned to pick from
+	generate := limit
+	if prefs.ranked() {
+		generate = maxRankedSchedules
+	}
+	schedules, truncated := generateSchedules(sections, generate)
+	meta := map[string]any{
+		"term":    term,
+		"courses": wanted,
+	}
+	if prefs.ranked() {
+		// Only the combinations generated are ranked, the best schedule
+		// may lie beyond them when the search stopped early
+		meta["ranking"] = map[string]any{
+			"considered": len(schedules),
+			"max":        maxRankedSchedules,
+			"complete":   !truncated,
+		}
+		prefs.rank(schedules)
+		if len(schedules) > limit {
+			schedules = schedules[:limit]
+			truncated = true
+		}
+	}
+	for i := range schedules {
+		schedules[i].Walking = walkingWarnings(schedules[i].Sections)
+	}
+	meta["count"] = len(schedules)
+	meta["truncated"] = truncated
+	writeData(w, schedules, meta)
+}
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"slices"
+	"testing"
+)
+
+/*
+ * Build a section meeting once on the given days
+ */
+func testSection(crn int, days string, start int, end int) Course {
+	return Course{
+		Crn:      crn,
+		Credits:  3,
+		Meetings: []Meeting{{Days: parseDays(&days), Start: &start, End: &end}},
+	}
+}
+
+func TestMeetingsOverlap(t *testing.T) {
+	tba := Meeting{Days: []string{"M"}}
+	tests := []struct {
+		name string
+		a, b Meeting
+		want bool
+	}{
+		{"same time and day", testSection(1, "MWF", 540, 590).Meetings[0], testSection(2, "M", 540, 590).Meetings[0], true},
+		{"partial overlap", testSection(1, "TR", 540, 615).Meetings[0], testSection(2, "R", 600, 650).Meetings[0], true},
+		{"back to back", testSection(1, "MWF", 540, 590).Meetings[0], testSection(2, "MWF", 590, 640).Meetings[0], false},
+		{"different days", testSection(1, "MWF", 540, 590).Meetings[0], testSection(2, "TR", 540, 590).Meetings[0], false},
+		{"tba time", tba, testSection(2, "M", 540, 590).Meetings[0], false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := meetingsOverlap(tt.a, tt.b); got != tt.want {
+				t.Errorf("meetingsOverlap(a, b) = %v, want %v", got, tt.want)
+			}
+			if got := meetingsOverlap(tt.b, tt.a); got != tt.want {
+				t.Errorf("meetingsOverlap(b, a) = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateSchedules(t *testing.T) {
+	tests := []struct {
+		name          string
+		sections      [][]Course
+		limit         int
+		want          [][]int
+		wantTruncated bool
+	}{
+		{
+			// The second course is searched first, crns still follow the request
+			name: "every combination without conflicts",
+			sections: [][]Course{
+				{testSection(1, "MWF", 540, 590), testSection(2, "MWF", 600, 650)},
+				{testSection(3, "TR", 540, 615)},
+			},
+			limit: 10,
+			want:  [][]int{{1, 3}, {2, 3}},
+		},
+		{
+			name: "conflicting sections are pruned",
+			sections: [][]Course{
+				{testSection(1, "MWF", 540, 590), testSection(2, "MWF", 600, 650)},
+				{testSection(3, "MW", 560, 610), testSection(4, "TR", 540, 615)},
+			},
+			limit: 10,
+			want:  [][]int{{1, 4}, {2, 4}},
+		},
+		{
+			name: "no schedule when every pair conflicts",
+			sections: [][]Course{
+				{testSection(1, "MWF", 540, 590)},
+				{testSection(2, "M", 570, 620)},
+			},
+			limit: 10,
+			want:  [][]int{},
+		},
+		{
+			name: "limit reached with more to find",
+			sections: [][]Course{
+				{testSection(1, "M", 540, 590), testSection(2, "W", 540, 590), testSection(3, "F", 540, 590)},
+			},
+			limit:         2,
+			want:          [][]int{{1}, {2}},
+			wantTruncated: true,
+		},
+		{
+			name: "limit equal to the schedules found",
+			sections: [][]Course{
+				{testSection(1, "M", 540, 590), testSection(2, "W", 540, 590)},
+			},
+			limit: 2,
+			want:  [][]int{{1}, {2}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedules, truncated := generateSchedules(tt.sections, tt.limit)
+			got := [][]int{}
+			for _, s := range schedules {
+				got = append(got, s.Crns)
+			}
+			if !slices.EqualFunc(got, tt.want, slices.Equal) {
+				t.Errorf("crns = %v, want %v", got, tt.want)
+			}
+			if truncated != tt.wantTruncated {
+				t.Errorf("truncated = %v, want %v", truncated, tt.wantTruncated)
+			}
+		})
+	}
+}
+
+func TestGenerateSchedulesCredits(t *testing.T) {
+	sections := [][]Course{
+		{testSection(1, "M", 540, 590)},
+		{testSection(2, "T", 540, 590)},
+	}
+	sections[1][0].Credits = 4
+	schedules, _ := generateSchedules(sections, 10)
+	if len(schedules) != 1 || schedules[0].Credits != 7 {
+		t.Fatalf("schedules = %+v, want one of 7 credits", schedules)
+	}
+}
+
+func TestGenerateSchedulesStepLimit(t *testing.T) {
+	// Every section of the second course conflicts with every section
+	// of the first, so the search tries each pair and finds nothing
+	first := make([]Course, 1000)
+	second := make([]Course, 1001)
+	for i := range first {
+		first[i] = testSection(i+1, "M", 540, 590)
+	}
+	for i := range second {
+		second[i] = testSection(len(first)+i+1, "M", 560, 610)
+	}
+	if len(first)*(len(second)+1) <= maxScheduleSteps {
+		t.Fatalf("test needs more than %d steps", maxScheduleSteps)
+	}
+
+	schedules, truncated := generateSchedules([][]Course{second, first}, 10)
+	if len(schedules) != 0 {
+		t.Errorf("got %d schedules, want none", len(schedules))
+	}
+	if !truncated {
+		t.Error("truncated = false, want true after maxScheduleSteps")
+	}
+
+	// Just under maxScheduleSteps the same search completes
+	schedules, truncated = generateSchedules([][]Course{second[:999], first[:999]}, 10)
+	if len(schedules) != 0 || truncated {
+		t.Errorf("got %d schedules truncated %v, want none and complete", len(schedules), truncated)
+	}
+}
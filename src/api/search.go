@@ -0,0 +1,326 @@
+/*
+ * file: search.go
+ * Description:
+ *   Free text course search. Every course gets a search
+ *   document of lowercase tokens when it is inserted:
+ *
+ *     code       : cs, 125, cs125 and the CRN
+ *     title      : words of the title, their prefixes (calc for
+ *                  calculus) and roman numerals as digits (i -> 1)
+ *     instructor : words of the name and their prefixes
+ *     info       : words of the notes of the course and its children
+ *
+ *   The search document is covered by the text index from
+ *   indexes.go. Results are ranked by how many of the query
+ *   tokens they contain, then by the weighted text score.
+ */
+package api
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// Shortest prefix indexed for partial words
+const minPrefixLength = 3
+
+// Most query tokens used in a search
+const maxQueryTokens = 10
+
+// Results returned when no limit is given
+const defaultSearchLimit = 50
+
+// A term whose text index has not been built yet, see backfillSearch
+var errNoSearchIndex = errors.New("search is not available yet for term")
+
+var romanNumerals = map[string]string{
+	"i":   "1",
+	"ii":  "2",
+	"iii": "3",
+	"iv":  "4",
+	"v":   "5",
+}
+
+type searchFields struct {
+	Code       []string `bson:"code"`
+	Title      []string `bson:"title"`
+	Instructor []string `bson:"instructor"`
+	Info       []string `bson:"info"`
+}
+
+type searchResult struct {
+	Score float64 `json:"score"`
+	Term  string  `json:"term"`
+	Course
+}
+
+/*
+ * Split text into lowercase tokens of letters and digits. Tokens
+ * mixing both (cs125) are also split where they change (cs, 125).
+ */
+func tokenize(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	tokens := []string{}
+	for _, word := range words {
+		tokens = append(tokens, word)
+		start := 0
+		for i := 1; i < len(word); i++ {
+			if unicode.IsDigit(rune(word[i])) != unicode.IsDigit(rune(word[i-1])) {
+				tokens = append(tokens, word[start:i])
+				start = i
+			}
+		}
+		if start > 0 {
+			tokens = append(tokens, word[start:])
+		}
+	}
+	return tokens
+}
+
+/*
+ * Add the prefixes of every word token so partial words match
+ */
+func withPrefixes(tokens []string) []string {
+	out := append([]string{}, tokens...)
+	for _, token := range tokens {
+		if _, err := strconv.Atoi(token); err == nil {
+			continue
+		}
+		for n := minPrefixLength; n < len(token); n++ {
+			out = append(out, token[:n])
+		}
+	}
+	return out
+}
+
+/*
+ * Remove repeated tokens keeping the first of each
+ */
+func dedupe(tokens []string) []string {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, token := range tokens {
+		if !seen[token] {
+			seen[token] = true
+			out = append(out, token)
+		}
+	}
+	return out
+}
+
+/*
+ * Build the search document of a course
+ */
+func buildSearch(c *Course) *searchFields {
+	subject := strings.ToLower(c.CourseCategory)
+	number := strconv.Itoa(c.CourseId)
+	code := []string{subject, number, subject + number}
+	if c.Crn != 0 {
+		code = append(code, strconv.Itoa(c.Crn))
+	}
+
+	title := tokenize(c.Title)
+	for _, token := range title {
+		if digit, ok := romanNumerals[token]; ok {
+			title = append(title, digit)
+		}
+	}
+
+	info := []string{}
+	for n := c; n != nil; n = n.CourseChild {
+		if n.Info != nil {
+			info = append(info, tokenize(*n.Info)...)
+		}
+	}
+
+	return &searchFields{
+		Code:       dedupe(code),
+		Title:      dedupe(withPrefixes(title)),
+		Instructor: dedupe(withPrefixes(tokenize(c.Instructor))),
+		Info:       dedupe(info),
+	}
+}
+
+/*
+ * GET /search?q=
+ * Search the courses of a term, or of several with terms=, by
+ * subject, number, title, instructor, CRN and notes, best matches first
+ */
+func searchHandler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	err := checkParams(params, map[string]bool{"q": true, "semester": true, "terms": true, "limit": true})
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+
+	q, err := singleParam(params, "q")
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	tokens := dedupe(tokenize(q))
+	if len(tokens) == 0 {
+		writeErr(w, r, badRequestf("q must contain at least one letter or digit"))
+		return
+	}
+	if len(tokens) > maxQueryTokens {
+		tokens = tokens[:maxQueryTokens]
+	}
+
+	limit := defaultSearchLimit
+	limitParam, err := singleParam(params, "limit")
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	if limitParam != "" {
+		limit, err = strconv.Atoi(limitParam)
+		if err != nil || limit < 1 || limit > maxLimit {
+			writeErr(w, r, badRequestf("limit must be an integer from 1 to %d, got %q", maxLimit, limitParam))
+			return
+		}
+	}
+
+	// Search the given terms, or the latest term unless one is given
+	semester, err := singleParam(params, "semester")
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	termsParam, err := singleParam(params, "terms")
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	var terms []string
+	switch {
+	case semester != "" && termsParam != "":
+		writeErr(w, r, badRequestf("semester and terms cannot be used together"))
+		return
+	case termsParam != "":
+		terms, err = selectTerms(r.Context(), termsParam)
+		if err != nil {
+			writeErr(w, r, err)
+			return
+		}
+	case semester != "":
+		if _, err = termCollection(r.Context(), semester); errors.Is(err, errUnknownTerm) {
+			writeError(w, http.StatusNotFound, codeNotFound, "unknown semester: "+semester)
+			return
+		} else if err != nil {
+			writeErr(w, r, err)
+			return
+		}
+		terms = []string{semester}
+	default:
+		scraped, err := listTerms(r.Context())
+		if err != nil {
+			writeErr(w, r, err)
+			return
+		}
+		if len(scraped) == 0 {
+			writeError(w, http.StatusNotFound, codeNotFound, "no terms have been scraped")
+			return
+		}
+		semester = scraped[len(scraped)-1]
+		terms = []string{semester}
+	}
+
+	// Newest term first so it wins equal scores
+	results := []searchResult{}
+	for i := len(terms) - 1; i >= 0; i-- {
+		found, err := searchTerm(r.Context(), terms[i], tokens, limit)
+		if err != nil {
+			writeErr(w, r, err)
+			return
+		}
+		results = append(results, found...)
+	}
+	slices.SortStableFunc(results, func(a, b searchResult) int {
+		return cmp.Compare(b.Score, a.Score)
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	meta := map[string]any{
+		"query":  q,
+		"tokens": tokens,
+		"count":  len(results),
+	}
+	if termsParam != "" {
+		meta["terms"] = terms
+	} else {
+		meta["semester"] = semester
+	}
+	writeData(w, results, meta)
+}
+
+/*
+ * Find the best matches of the query tokens in one term
+ * Arguments:
+ *   ctx : context bounding the query
+ *   term : scraped term to search
+ *   tokens : tokens of the query
+ *   limit : most results to return
+ * Returns:
+ *   the results, best match first
+ */
+func searchTerm(ctx context.Context, term string, tokens []string, limit int) ([]searchResult, error) {
+	db := mongoClient.Database(coursesDatabase).Collection(term)
+
+	allTokens := bson.D{{Key: "$concatArrays", Value: bson.A{
+		bson.D{{Key: "$ifNull", Value: bson.A{"$search.code", bson.A{}}}},
+		bson.D{{Key: "$ifNull", Value: bson.A{"$search.title", bson.A{}}}},
+		bson.D{{Key: "$ifNull", Value: bson.A{"$search.instructor", bson.A{}}}},
+		bson.D{{Key: "$ifNull", Value: bson.A{"$search.info", bson.A{}}}},
+	}}}
+	cursor, err := db.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{{Key: "$text", Value: bson.D{
+			{Key: "$search", Value: strings.Join(tokens, " ")},
+		}}}}},
+		{{Key: "$addFields", Value: bson.D{
+			{Key: "_score", Value: bson.D{{Key: "$meta", Value: "textScore"}}},
+			{Key: "_matched", Value: bson.D{{Key: "$size", Value: bson.D{
+				{Key: "$setIntersection", Value: bson.A{allTokens, tokens}},
+			}}}},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "_matched", Value: -1}, {Key: "_score", Value: -1}}}},
+		{{Key: "$limit", Value: limit}},
+	})
+	if isIndexNotFound(err) {
+		return nil, fmt.Errorf("%w: %s", errNoSearchIndex, term)
+	} else if err != nil {
+		return nil, err
+	}
+
+	var found []struct {
+		Score   float64 `bson:"_score"`
+		Matched int     `bson:"_matched"`
+		Course  `bson:",inline"`
+	}
+	if err = cursor.All(ctx, &found); err != nil {
+		return nil, err
+	}
+
+	// Each query token contained is worth more than any text score
+	results := make([]searchResult, len(found))
+	for i, f := range found {
+		results[i] = searchResult{Score: float64(f.Matched)*100 + f.Score, Term: term, Course: f.Course}
+	}
+	return results, nil
+}
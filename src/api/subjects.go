@@ -0,0 +1,210 @@
+/*
+ * file: subjects.go
+ * Description:
+ *   Registry of subjects (CS, MTH) with the name of their
+ *   department and school. Names are seeded from Subjects
+ *   in config; every code scraped is also recorded in
+ *   metaDatabase, so subjects missing from config still
+ *   show up (without a name) until they are added.
+ */
+package api
+
+import (
+	"cmp"
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"wilkesu-scrapy/config"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Collection in metaDatabase holding every subject code scraped
+const subjectsCollection = "subjects"
+
+// Subjects of config by code, read once
+var subjectRegistry = sync.OnceValue(func() map[string]config.Subject {
+	registry := map[string]config.Subject{}
+	for code, subject := range config.LoadConfig().Subjects {
+		registry[strings.ToUpper(code)] = subject
+	}
+	return registry
+})
+
+type termSections struct {
+	Term     string `json:"term"`
+	Sections int    `json:"sections"`
+}
+
+type subject struct {
+	Code     string         `json:"code"`
+	Name     string         `json:"name"`   // Department, empty until added to config
+	School   string         `json:"school"` // School or college of the department
+	Sections int            `json:"sections"`
+	Terms    []termSections `json:"terms"` // Sections of each term selected, oldest first
+}
+
+type seenSubject struct {
+	Code      string    `bson:"_id"`
+	Terms     []string  `bson:"terms"`
+	FirstSeen time.Time `bson:"first_seen"`
+	LastSeen  time.Time `bson:"last_seen"`
+}
+
+/*
+ * Return the subject codes of courses without repeats
+ */
+func subjectCodes(courses []Course) []string {
+	codes := []string{}
+	for _, c := range courses {
+		code := strings.ToUpper(strings.TrimSpace(c.CourseCategory))
+		if code != "" && !slices.Contains(codes, code) {
+			codes = append(codes, code)
+		}
+	}
+	return codes
+}
+
+/*
+ * Record the subject codes of a scraped term
+ * Arguments:
+ *   ctx : context bounding the writes
+ *   term : term the codes were seen in
+ *   codes : subject codes of the term
+ */
+func recordSubjects(ctx context.Context, term string, codes []string) error {
+	db := mongoClient.Database(metaDatabase).Collection(subjectsCollection)
+	now := time.Now().UTC()
+	for _, code := range codes {
+		_, err := db.UpdateOne(
+			ctx,
+			bson.D{{Key: "_id", Value: code}},
+			bson.D{
+				{Key: "$addToSet", Value: bson.D{{Key: "terms", Value: term}}},
+				{Key: "$set", Value: bson.D{{Key: "last_seen", Value: now}}},
+				{Key: "$setOnInsert", Value: bson.D{{Key: "first_seen", Value: now}}},
+			},
+			options.UpdateOne().SetUpsert(true),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+ * List every subject of the registry with its sections in terms
+ * Arguments:
+ *   ctx : context bounding the queries
+ *   terms : scraped terms to count, oldest first
+ * Returns:
+ *   the subjects of config and those scraped, sorted by code
+ */
+func subjectDirectory(ctx context.Context, terms []string) ([]subject, error) {
+	subjects := map[string]*subject{}
+	add := func(code string) *subject {
+		code = strings.ToUpper(code)
+		if s, ok := subjects[code]; ok {
+			return s
+		}
+		s := &subject{Code: code, Terms: []termSections{}}
+		if named, ok := subjectRegistry()[code]; ok {
+			s.Name, s.School = named.Name, named.School
+		}
+		subjects[code] = s
+		return s
+	}
+
+	for code := range subjectRegistry() {
+		add(code)
+	}
+	cursor, err := mongoClient.Database(metaDatabase).Collection(subjectsCollection).Find(ctx, bson.D{})
+	if err != nil {
+		return nil, err
+	}
+	seen := []seenSubject{}
+	if err = cursor.All(ctx, &seen); err != nil {
+		return nil, err
+	}
+	for _, s := range seen {
+		add(s.Code)
+	}
+
+	if len(terms) > 0 {
+		db, source := termsPipeline(terms, bson.D{})
+		source = append(source, bson.D{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: bson.D{{Key: "subject", Value: "$coursecategory"}, {Key: "term", Value: "$term"}}},
+			{Key: "sections", Value: bson.D{{Key: "$sum", Value: 1}}},
+		}}})
+		cursor, err := db.Aggregate(ctx, source)
+		if err != nil {
+			return nil, err
+		}
+		var groups []struct {
+			ID struct {
+				Subject string `bson:"subject"`
+				Term    string `bson:"term"`
+			} `bson:"_id"`
+			Sections int `bson:"sections"`
+		}
+		if err = cursor.All(ctx, &groups); err != nil {
+			return nil, err
+		}
+		for _, g := range groups {
+			if g.ID.Subject == "" {
+				continue
+			}
+			// Terms scraped before the registry existed were never recorded
+			s := add(g.ID.Subject)
+			s.Sections += g.Sections
+			s.Terms = append(s.Terms, termSections{Term: g.ID.Term, Sections: g.Sections})
+		}
+	}
+
+	directory := []subject{}
+	for _, s := range subjects {
+		slices.SortFunc(s.Terms, func(a, b termSections) int {
+			return cmp.Compare(termKey(a.Term), termKey(b.Term))
+		})
+		directory = append(directory, *s)
+	}
+	slices.SortFunc(directory, func(a, b subject) int {
+		return cmp.Compare(a.Code, b.Code)
+	})
+	return directory, nil
+}
+
+/*
+ * GET /v1/subjects
+ * List every subject with its name and sections per term
+ */
+func subjectsHandler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	if err := checkParams(params, map[string]bool{"terms": true}); err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	termsParam, err := singleParam(params, "terms")
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+
+	terms, err := selectTerms(r.Context(), termsParam)
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	directory, err := subjectDirectory(r.Context(), terms)
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	writeData(w, directory, map[string]any{"terms": terms, "count": len(directory)})
+}
@@ -0,0 +1,211 @@
+/*
+ * file: terms.go
+ * Description:
+ *   Helpers for the term collections. Every scraped
+ *   term is stored in coursesDatabase in a collection
+ *   named semester + year (e.g.: F25, Sp24).
+ */
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+var errUnknownTerm = errors.New("unknown term")
+
+// Order of the semesters within a year
+var semesterOrder = map[string]int{
+	"Sp": 0,
+	"F":  1,
+}
+
+/*
+ * Return the names of every term collection, oldest first
+ */
+func listTerms(ctx context.Context) ([]string, error) {
+	terms, err := mongoClient.Database(coursesDatabase).ListCollectionNames(ctx, bson.D{})
+	if err != nil {
+		return nil, err
+	}
+	// Staging collections of scrapes in progress are not terms
+	terms = slices.DeleteFunc(terms, func(term string) bool {
+		_, _, ok := parseTerm(term)
+		return !ok
+	})
+	sortTerms(terms)
+	return terms, nil
+}
+
+/*
+ * Split a term into its semester and year
+ * Arguments:
+ *   term : term name (e.g.: F25, Sp2024)
+ * Returns:
+ *   semester, year and whether the term is well formed
+ */
+func parseTerm(term string) (string, int, bool) {
+	i := strings.IndexAny(term, "0123456789")
+	if i <= 0 {
+		return "", 0, false
+	}
+	semester := term[:i]
+	if _, ok := semesterOrder[semester]; !ok {
+		return "", 0, false
+	}
+	year, err := strconv.Atoi(term[i:])
+	if err != nil {
+		return "", 0, false
+	}
+	// Years are scraped as two digits (F25) but may be given as four
+	if year >= 2000 {
+		year -= 2000
+	}
+	return semester, year, true
+}
+
+/*
+ * Return the chronological position of a term, unknown names go last
+ */
+func termKey(term string) int {
+	semester, year, ok := parseTerm(term)
+	if !ok {
+		return int(^uint(0) >> 1)
+	}
+	return year*len(semesterOrder) + semesterOrder[semester]
+}
+
+/*
+ * Sort terms chronologically, unknown names go last
+ */
+func sortTerms(terms []string) {
+	sort.SliceStable(terms, func(i, j int) bool {
+		return termKey(terms[i]) < termKey(terms[j])
+	})
+}
+
+/*
+ * Select scraped terms from a terms parameter
+ * Arguments:
+ *   ctx : context bounding the lookup
+ *   value : all (or empty), a range such as F23-Sp25 (either
+ *           end may be left out) or a list such as F24,Sp25
+ * Returns:
+ *   the selected terms oldest first, a badRequest if value is
+ *   malformed, or errUnknownTerm if a listed term was not scraped
+ */
+func selectTerms(ctx context.Context, value string) ([]string, error) {
+	scraped, err := listTerms(ctx)
+	if err != nil {
+		return nil, err
+	}
+	value = strings.TrimSpace(value)
+	if value == "" || strings.EqualFold(value, "all") {
+		return scraped, nil
+	}
+
+	if from, to, isRange := strings.Cut(value, "-"); isRange {
+		first, last := 0, int(^uint(0)>>1)
+		if from != "" {
+			if _, _, ok := parseTerm(from); !ok {
+				return nil, badRequestf("terms range must be two terms such as F23-Sp25, got %q", value)
+			}
+			first = termKey(from)
+		}
+		if to != "" {
+			if _, _, ok := parseTerm(to); !ok {
+				return nil, badRequestf("terms range must be two terms such as F23-Sp25, got %q", value)
+			}
+			last = termKey(to)
+		}
+		selected := []string{}
+		for _, term := range scraped {
+			if key := termKey(term); key >= first && key <= last {
+				selected = append(selected, term)
+			}
+		}
+		return selected, nil
+	}
+
+	known := map[string]bool{}
+	for _, term := range scraped {
+		known[term] = true
+	}
+	selected := []string{}
+	for _, term := range strings.Split(value, ",") {
+		term = strings.TrimSpace(term)
+		if _, _, ok := parseTerm(term); !ok {
+			return nil, badRequestf("terms must be all, a range such as F23-Sp25 or a list such as F24,Sp25, got %q", value)
+		}
+		if !known[term] {
+			return nil, fmt.Errorf("%w: %s", errUnknownTerm, term)
+		}
+		if !slices.Contains(selected, term) {
+			selected = append(selected, term)
+		}
+	}
+	sortTerms(selected)
+	return selected, nil
+}
+
+/*
+ * Build the pipeline reading the courses matching filter from
+ * several terms, each course gets the term it came from and
+ * its position for the term sort key
+ * Arguments:
+ *   terms : scraped terms to read, at least one
+ *   filter : course filter, e.g. from parseCourseFilter
+ * Returns:
+ *   the collection to aggregate on and the pipeline
+ */
+func termsPipeline(terms []string, filter bson.D) (*mongo.Collection, mongo.Pipeline) {
+	stages := func(term string) mongo.Pipeline {
+		return mongo.Pipeline{
+			{{Key: "$match", Value: filter}},
+			{{Key: "$addFields", Value: bson.D{
+				{Key: "term", Value: term},
+				{Key: "termorder", Value: termKey(term)},
+			}}},
+		}
+	}
+	db := mongoClient.Database(coursesDatabase)
+	pipeline := stages(terms[0])
+	for _, term := range terms[1:] {
+		pipeline = append(pipeline, bson.D{{Key: "$unionWith", Value: bson.D{
+			{Key: "coll", Value: term},
+			{Key: "pipeline", Value: stages(term)},
+		}}})
+	}
+	return db.Collection(terms[0]), pipeline
+}
+
+/*
+ * Return the collection of a term that has been scraped
+ * Arguments:
+ *   ctx : context bounding the lookup
+ *   term : term name from the request
+ * Returns:
+ *   the collection, errUnknownTerm if no such term was scraped
+ */
+func termCollection(ctx context.Context, term string) (*mongo.Collection, error) {
+	if _, _, ok := parseTerm(term); !ok {
+		return nil, fmt.Errorf("%w: %s", errUnknownTerm, term)
+	}
+	db := mongoClient.Database(coursesDatabase)
+	names, err := db.ListCollectionNames(ctx, bson.D{{Key: "name", Value: term}})
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("%w: %s", errUnknownTerm, term)
+	}
+	return db.Collection(term), nil
+}
@@ -0,0 +1,195 @@
+/*
+ * file: times.go
+ * Description:
+ *   Normalises the scraped meeting times into minutes
+ *   after midnight. The roster only gives the meridiem
+ *   of the end time (0900-0950AM, 1100-1250PM), so the
+ *   meridiem of the start time is inferred from it.
+ *
+ *   A section meets on the days and times of its own row
+ *   plus those of any extra time rows (course children),
+ *   each of which becomes one Meeting.
+ */
+package api
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Day letters used by the roster, in weekday order
+const weekDays = "MTWRFSU"
+
+// One weekly meeting of a section
+type Meeting struct {
+	Days            []string `bson:"days" json:"days"`             // M; T; W; R; F; S; U
+	Start           *int     `bson:"start" json:"start,omitempty"` // Minutes after midnight; null for TBA
+	End             *int     `bson:"end" json:"end,omitempty"`     // Minutes after midnight; null for TBA
+	Location        *string  `bson:"location" json:"location,omitempty"`
+	RoomNum         *int     `bson:"roomnum" json:"room_num,omitempty"`
+	Room            *string  `bson:"room" json:"room,omitempty"`                        // 108; 108A; GYM
+	BuildingName    *string  `bson:"buildingname" json:"building_name,omitempty"`       // Name of Location from the registry
+	UnknownBuilding bool     `bson:"unknownbuilding" json:"unknown_building,omitempty"` // Location is a code the registry lacks
+}
+
+/*
+ * Parse an "HH:MM" time on a 12 hour clock
+ * Returns:
+ *   minutes after midnight ignoring the meridiem and whether it parsed
+ */
+func clockMinutes(hhmm string) (int, bool) {
+	hours, minutes, found := strings.Cut(hhmm, ":")
+	if !found {
+		return 0, false
+	}
+	h, err := strconv.Atoi(hours)
+	if err != nil || h < 1 || h > 12 {
+		return 0, false
+	}
+	m, err := strconv.Atoi(minutes)
+	if err != nil || m < 0 || m > 59 {
+		return 0, false
+	}
+	return (h%12)*60 + m, true
+}
+
+/*
+ * Convert the scraped start time, end time and end meridiem
+ * into minutes after midnight
+ * Arguments:
+ *   start : StartTime of a course (e.g.: 11:00)
+ *   end : EndTime of a course (e.g.: 12:50)
+ *   ampm : EndTimeAMPM of a course (AM or PM)
+ * Returns:
+ *   start and end minutes, false for TBA or malformed times
+ */
+func meetingMinutes(start *string, end *string, ampm *string) (int, int, bool) {
+	if start == nil || end == nil || ampm == nil {
+		return 0, 0, false
+	}
+	startMin, ok := clockMinutes(*start)
+	if !ok {
+		return 0, 0, false
+	}
+	endMin, ok := clockMinutes(*end)
+	if !ok {
+		return 0, 0, false
+	}
+
+	switch *ampm {
+	case "AM":
+		return startMin, endMin, true
+	case "PM":
+		endMin += 12 * 60
+		// 0100-0250PM starts in the afternoon, 1100-1250PM in the morning
+		if startMin+12*60 <= endMin {
+			startMin += 12 * 60
+		}
+		return startMin, endMin, true
+	}
+	return 0, 0, false
+}
+
+/*
+ * Split the scraped Day of a course into day letters
+ * Returns:
+ *   the days in weekday order, empty for TBA or anything unexpected
+ */
+func parseDays(day *string) []string {
+	days := []string{}
+	if day == nil {
+		return days
+	}
+	for _, d := range strings.ToUpper(*day) {
+		if !strings.ContainsRune(weekDays, d) {
+			return []string{}
+		}
+	}
+	for _, d := range weekDays {
+		if strings.ContainsRune(strings.ToUpper(*day), d) {
+			days = append(days, string(d))
+		}
+	}
+	return days
+}
+
+/*
+ * Fill in the normalised fields of a course and its children,
+ * and collect the meetings of the section with their buildings
+ */
+func normaliseCourse(c *Course) {
+	c.Meetings = []Meeting{}
+	for n := c; n != nil; n = n.CourseChild {
+		if start, end, ok := meetingMinutes(n.StartTime, n.EndTime, n.EndTimeAMPM); ok {
+			n.StartMinutes = &start
+			n.EndMinutes = &end
+		}
+
+		// Info rows carry no meeting
+		days := parseDays(n.Day)
+		if len(days) == 0 && n.StartMinutes == nil {
+			continue
+		}
+		m := Meeting{
+			Days:     days,
+			Start:    n.StartMinutes,
+			End:      n.EndMinutes,
+			Location: n.Location,
+			RoomNum:  n.RoomNum,
+			Room:     n.Room,
+		}
+		// Courses scraped before Room existed only have RoomNum
+		if m.Room == nil && m.RoomNum != nil {
+			room := strconv.Itoa(*m.RoomNum)
+			m.Room = &room
+		}
+		resolveBuilding(&m)
+		c.Meetings = append(c.Meetings, m)
+	}
+}
+
+/*
+ * Parse a time of day such as 10, 13:30, 10am or 1:30pm
+ * Returns:
+ *   minutes after midnight and whether it parsed
+ */
+func parseClock(value string) (int, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	meridiem := ""
+	if strings.HasSuffix(value, "am") || strings.HasSuffix(value, "pm") {
+		meridiem = value[len(value)-2:]
+		value = strings.TrimSpace(value[:len(value)-2])
+	}
+
+	hours, minutes, found := strings.Cut(value, ":")
+	h, err := strconv.Atoi(hours)
+	if err != nil {
+		return 0, false
+	}
+	m := 0
+	if found {
+		if len(minutes) != 2 {
+			return 0, false
+		}
+		m, err = strconv.Atoi(minutes)
+		if err != nil || m < 0 || m > 59 {
+			return 0, false
+		}
+	}
+
+	switch meridiem {
+	case "":
+		if h < 0 || h > 24 || (h == 24 && m != 0) {
+			return 0, false
+		}
+	default:
+		if h < 1 || h > 12 {
+			return 0, false
+		}
+		h %= 12
+		if meridiem == "pm" {
+			h += 12
+		}
+	}
+	return h*60 + m, true
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMeetingMinutes(t *testing.T) {
+	tests := []struct {
+		name       string
+		start, end *string
+		ampm       *string
+		wantStart  int
+		wantEnd    int
+		wantOK     bool
+	}{
+		{"morning", strp("08:00"), strp("08:50"), strp("AM"), 480, 530, true},
+		{"start before noon, end after", strp("11:00"), strp("12:50"), strp("PM"), 660, 770, true},
+		{"starting at noon", strp("12:00"), strp("12:50"), strp("PM"), 720, 770, true},
+		{"afternoon", strp("01:00"), strp("02:50"), strp("PM"), 780, 890, true},
+		{"evening", strp("06:30"), strp("09:15"), strp("PM"), 1110, 1275, true},
+		{"tba", strp("TBA"), strp("TBA"), strp("PM"), 0, 0, false},
+		{"nil start", nil, strp("08:50"), strp("AM"), 0, 0, false},
+		{"nil end", strp("08:00"), nil, strp("AM"), 0, 0, false},
+		{"nil meridiem", strp("08:00"), strp("08:50"), nil, 0, 0, false},
+		{"unknown meridiem", strp("08:00"), strp("08:50"), strp("XM"), 0, 0, false},
+		{"no colon", strp("0800"), strp("0850"), strp("AM"), 0, 0, false},
+		{"hour past 12", strp("13:00"), strp("13:50"), strp("PM"), 0, 0, false},
+		{"minute past 59", strp("08:00"), strp("08:60"), strp("AM"), 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, ok := meetingMinutes(tt.start, tt.end, tt.ampm)
+			if ok != tt.wantOK || start != tt.wantStart || end != tt.wantEnd {
+				t.Errorf("meetingMinutes() = %d, %d, %v, want %d, %d, %v",
+					start, end, ok, tt.wantStart, tt.wantEnd, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestParseClock(t *testing.T) {
+	tests := []struct {
+		value  string
+		want   int
+		wantOK bool
+	}{
+		{"10", 600, true},
+		{"13:30", 810, true},
+		{"0", 0, true},
+		{"24", 1440, true},
+		{"10am", 600, true},
+		{"1:30pm", 810, true},
+		{" 9 AM ", 540, true},
+		{"12am", 0, true},
+		{"12pm", 720, true},
+		{"", 0, false},
+		{"noon", 0, false},
+		{"-1", 0, false},
+		{"25", 0, false},
+		{"24:30", 0, false},
+		{"0pm", 0, false},
+		{"13pm", 0, false},
+		{"10:5", 0, false},
+		{"10:60", 0, false},
+		{"10:xx", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, ok := parseClock(tt.value)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("parseClock(%q) = %d, %v, want %d, %v", tt.value, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestParseDays(t *testing.T) {
+	tests := []struct {
+		name string
+		day  *string
+		want []string
+	}{
+		{"weekdays", strp("MWF"), []string{"M", "W", "F"}},
+		{"weekday order", strp("RT"), []string{"T", "R"}},
+		{"lower case", strp("tr"), []string{"T", "R"}},
+		{"weekend", strp("SU"), []string{"S", "U"}},
+		{"repeated", strp("MM"), []string{"M"}},
+		{"tba", strp("TBA"), []string{}},
+		{"unexpected letter", strp("MXF"), []string{}},
+		{"empty", strp(""), []string{}},
+		{"nil", nil, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseDays(tt.day)
+			if got == nil || !slices.Equal(got, tt.want) {
+				t.Errorf("parseDays() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func strp(s string) *string {
+	return &s
+}
@@ -0,0 +1,448 @@
+/*
+ * file: v1.go
+ * Description:
+ *   Versioned, resource oriented API under /v1/.
+ *   Every response is a JSON envelope, either
+ *
+ *     { "data": ..., "meta": { ... } }
+ *
+ *   or, for any 4xx / 5xx status,
+ *
+ *     { "error": { "code": "not_found", "message": "..." } }
+ *
+ *   The routes and error codes are documented in API.md.
+ */
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"wilkesu-scrapy/config"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// Largest request body accepted
+const maxBodyBytes = 1 << 16
+
+// Error codes returned in the error envelope
+const (
+	codeBadRequest       = "bad_request"
+	codeNotFound         = "not_found"
+	codeMethodNotAllowed = "method_not_allowed"
+	codeInternal         = "internal"
+	codeUnavailable      = "unavailable"
+)
+
+// Methods tried when telling a wrong method from an unknown route
+var routeMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+type envelope struct {
+	Data any `json:"data"`
+	Meta any `json:"meta,omitempty"`
+}
+
+type apiError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type errorEnvelope struct {
+	Error apiError `json:"error"`
+}
+
+type termInfo struct {
+	Term        string     `json:"term"`
+	Semester    string     `json:"semester"`
+	Year        int        `json:"year"`
+	Courses     int        `json:"courses,omitempty"`
+	LastScraped *time.Time `json:"last_scraped,omitempty"`
+}
+
+type subjectCount struct {
+	Subject  string `bson:"_id" json:"subject"`
+	Name     string `bson:"-" json:"name"` // From the subject registry
+	Sections int    `bson:"sections" json:"sections"`
+}
+
+// A course along with the term it was offered in
+type termCourse struct {
+	Term   string `bson:"term" json:"term"`
+	Course `bson:",inline"`
+}
+
+// A missing resource reported back to the client as a 404
+type notFound struct {
+	msg string
+}
+
+func (e notFound) Error() string {
+	return e.msg
+}
+
+func notFoundf(format string, args ...any) error {
+	return notFound{msg: fmt.Sprintf(format, args...)}
+}
+
+/*
+ * Write data in the success envelope
+ */
+func writeData(w http.ResponseWriter, data any, meta any) {
+	writeJSON(w, http.StatusOK, envelope{Data: data, Meta: meta})
+}
+
+/*
+ * Write an error envelope with the given status code
+ */
+func writeError(w http.ResponseWriter, status int, code string, message string) {
+	writeJSON(w, status, errorEnvelope{Error: apiError{Code: code, Message: message}})
+}
+
+/*
+ * Write the error envelope matching err, unknown errors are
+ * logged and reported as internal without their details
+ */
+func writeErr(w http.ResponseWriter, r *http.Request, err error) {
+	var invalid badRequest
+	var missing notFound
+	switch {
+	case errors.As(err, &invalid):
+		writeError(w, http.StatusBadRequest, codeBadRequest, invalid.msg)
+	case errors.As(err, &missing):
+		writeError(w, http.StatusNotFound, codeNotFound, missing.msg)
+	case errors.Is(err, errUnknownTerm):
+		writeError(w, http.StatusNotFound, codeNotFound, err.Error())
+	case errors.Is(err, mongo.ErrNoDocuments):
+		writeError(w, http.StatusNotFound, codeNotFound, "resource not found")
+	case errors.Is(err, errNoSearchIndex):
+		writeError(w, http.StatusServiceUnavailable, codeUnavailable, err.Error())
+	default:
+		slog.Error("Request failed", "path", r.URL.Path, "err", err)
+		writeError(w, http.StatusInternalServerError, codeInternal, "internal server error")
+	}
+}
+
+/*
+ * Decode the JSON body of a request into v
+ * Returns:
+ *   a badRequest if the body is too large, malformed or has unknown fields
+ */
+func readJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return badRequestf("body must be at most %d bytes", maxBodyBytes)
+		}
+		return badRequestf("invalid body: %v", err)
+	}
+	if decoder.More() {
+		return badRequestf("body must hold a single JSON object")
+	}
+	return nil
+}
+
+/*
+ * Register the /v1/ routes on mux
+ * Arguments:
+ *   mux : mux to register on
+ *   cfg : configuration of the handlers that need it
+ */
+func registerV1(mux *http.ServeMux, cfg config.Configuration) {
+	mux.HandleFunc("GET /v1/terms", termsHandler)
+	mux.HandleFunc("GET /v1/terms/{term}/courses", termCoursesHandler)
+	mux.HandleFunc("GET /v1/terms/{term}/courses/{crn}", termCourseHandler)
+	mux.HandleFunc("GET /v1/terms/{term}/subjects", termSubjectsHandler)
+	mux.HandleFunc("POST /v1/terms/{term}/conflicts", conflictsHandler)
+	mux.HandleFunc("GET /v1/terms/{term}/calendar.ics", calendarHandler(cfg))
+	mux.HandleFunc("GET /v1/terms/{term}/rooms", roomsHandler)
+	mux.HandleFunc("GET /v1/terms/{term}/rooms/free", freeRoomsHandler)
+	mux.HandleFunc("GET /v1/courses", coursesHandler)
+	mux.HandleFunc("GET /v1/catalog/{subject}/{number}", catalogHandler)
+	mux.HandleFunc("GET /v1/instructors", instructorsHandler)
+	mux.HandleFunc("GET /v1/instructors/{id}", instructorHandler)
+	mux.HandleFunc("GET /v1/subjects", subjectsHandler)
+	mux.HandleFunc("GET /v1/analytics/fill-rates", fillRatesHandler)
+	mux.HandleFunc("GET /v1/analytics/waitlisted", waitlistedHandler)
+	mux.HandleFunc("GET /v1/analytics/fastest-filling", fastestFillingHandler)
+	mux.HandleFunc("GET /v1/analytics/supply-demand", supplyDemandHandler)
+	mux.HandleFunc("GET /v1/analytics/delivery-modes", deliveryModesHandler)
+	mux.HandleFunc("GET /v1/buildings", buildingsHandler)
+	mux.HandleFunc("GET /v1/buildings/unknown", unknownBuildingsHandler)
+	mux.HandleFunc("GET /v1/schedules/generate", generateSchedulesHandler)
+	mux.HandleFunc("POST /v1/schedules", saveScheduleHandler)
+	mux.HandleFunc("GET /v1/schedules/{id}", savedScheduleHandler)
+	mux.HandleFunc("/v1/", func(w http.ResponseWriter, r *http.Request) {
+		// The catch-all matches any method, so a route called with the
+		// wrong method lands here rather than in the mux's 405
+		allowed := []string{}
+		for _, method := range routeMethods {
+			probe := r.Clone(r.Context())
+			probe.Method = method
+			if _, pattern := mux.Handler(probe); pattern != "" && pattern != "/v1/" {
+				allowed = append(allowed, method)
+			}
+		}
+		if len(allowed) > 0 {
+			writeMethodNotAllowed(w, r, allowed)
+			return
+		}
+		writeError(w, http.StatusNotFound, codeNotFound, "no such route: "+r.URL.Path)
+	})
+}
+
+/*
+ * Write the 405 error envelope listing the methods of the route
+ */
+func writeMethodNotAllowed(w http.ResponseWriter, r *http.Request, allowed []string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed,
+		fmt.Sprintf("method %s not allowed on %s, use %s", r.Method, r.URL.Path, strings.Join(allowed, ", ")))
+}
+
+// Replaces the plain text 405 of ServeMux with the error envelope
+type methodErrorWriter struct {
+	http.ResponseWriter
+	r         *http.Request
+	wrote     bool
+	swallowed bool
+}
+
+func (w *methodErrorWriter) WriteHeader(status int) {
+	if status == http.StatusMethodNotAllowed && !w.wrote {
+		w.wrote, w.swallowed = true, true
+		// ServeMux has set Allow and the headers of a text body
+		allowed := strings.Split(w.Header().Get("Allow"), ", ")
+		w.Header().Del("Content-Type")
+		w.Header().Del("X-Content-Type-Options")
+		writeMethodNotAllowed(w.ResponseWriter, w.r, allowed)
+		return
+	}
+	w.wrote = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *methodErrorWriter) Write(b []byte) (int, error) {
+	if w.swallowed {
+		return len(b), nil
+	}
+	w.wrote = true
+	return w.ResponseWriter.Write(b)
+}
+
+/*
+ * Wrap a handler so 405 responses use the error envelope.
+ * No handler of the API answers 405 itself, only ServeMux does.
+ */
+func jsonMethodErrors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(&methodErrorWriter{ResponseWriter: w, r: r}, r)
+	})
+}
+
+/*
+ * GET /v1/terms
+ * List every scraped term, oldest first
+ */
+func termsHandler(w http.ResponseWriter, r *http.Request) {
+	terms, err := listTerms(r.Context())
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+
+	// Scrape records are optional, terms scraped before
+	// they existed simply have no last_scraped
+	records := map[string]scrapeRecord{}
+	cursor, err := mongoClient.Database(metaDatabase).Collection(scrapesCollection).Find(r.Context(), bson.D{})
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	var found []scrapeRecord
+	if err = cursor.All(r.Context(), &found); err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	for _, record := range found {
+		records[record.Term] = record
+	}
+
+	data := make([]termInfo, 0, len(terms))
+	for _, term := range terms {
+		semester, year, ok := parseTerm(term)
+		if !ok {
+			continue
+		}
+		info := termInfo{Term: term, Semester: semester, Year: 2000 + year}
+		if record, ok := records[term]; ok {
+			info.Courses = record.Courses
+			info.LastScraped = &record.FinishedAt
+		}
+		data = append(data, info)
+	}
+	writeData(w, data, map[string]int{"count": len(data)})
+}
+
+/*
+ * GET /v1/terms/{term}/courses
+ * List the courses of a term, filtered, sorted and paginated
+ * with the same parameters as /filter
+ */
+func termCoursesHandler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	if err := checkParams(params, filterParams, pageParams); err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	filter, err := parseCourseFilter(params)
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	p, err := parsePage(params)
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+
+	db, err := termCollection(r.Context(), r.PathValue("term"))
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+
+	courses, meta, err := queryCourses(r.Context(), db, filter, p)
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	writeData(w, courses, meta)
+}
+
+/*
+ * GET /v1/courses?terms=
+ * List the courses of several terms, each with its term, filtered,
+ * sorted and paginated with the same parameters as /filter
+ */
+func coursesHandler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	if err := checkParams(params, map[string]bool{"terms": true}, filterParams, pageParams); err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	termsParam, err := singleParam(params, "terms")
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	filter, err := parseCourseFilter(params)
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	p, err := parsePage(params)
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+
+	terms, err := selectTerms(r.Context(), termsParam)
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	if len(terms) == 0 {
+		writeData(w, []termCourse{}, map[string]any{"terms": terms, "total": 0, "count": 0})
+		return
+	}
+
+	db, source := termsPipeline(terms, filter)
+	raws, meta, err := queryPage(r.Context(), db, source, p)
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	courses := make([]termCourse, len(raws))
+	for i, raw := range raws {
+		if err = bson.Unmarshal(raw, &courses[i]); err != nil {
+			writeErr(w, r, err)
+			return
+		}
+	}
+	writeData(w, courses, struct {
+		Terms []string `json:"terms"`
+		pageMeta
+	}{terms, meta})
+}
+
+/*
+ * GET /v1/terms/{term}/courses/{crn}
+ * Get a single course of a term by CRN
+ */
+func termCourseHandler(w http.ResponseWriter, r *http.Request) {
+	crn, err := strconv.Atoi(r.PathValue("crn"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "crn must be an integer")
+		return
+	}
+
+	db, err := termCollection(r.Context(), r.PathValue("term"))
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+
+	var course Course
+	err = db.FindOne(r.Context(), bson.D{{Key: "crn", Value: crn}}).Decode(&course)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		writeError(w, http.StatusNotFound, codeNotFound, "unknown crn: "+r.PathValue("crn"))
+		return
+	} else if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	writeData(w, course, nil)
+}
+
+/*
+ * GET /v1/terms/{term}/subjects
+ * List the subjects of a term with their section counts
+ */
+func termSubjectsHandler(w http.ResponseWriter, r *http.Request) {
+	db, err := termCollection(r.Context(), r.PathValue("term"))
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+
+	cursor, err := db.Aggregate(r.Context(), mongo.Pipeline{
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$coursecategory"},
+			{Key: "sections", Value: bson.D{{Key: "$sum", Value: 1}}},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "_id", Value: 1}}}},
+	})
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	subjects := []subjectCount{}
+	if err = cursor.All(r.Context(), &subjects); err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	for i := range subjects {
+		subjects[i].Name = subjectRegistry()[strings.ToUpper(subjects[i].Subject)].Name
+	}
+	writeData(w, subjects, map[string]int{"count": len(subjects)})
+}
@@ -0,0 +1,182 @@
+/*
+ * file: walking.go
+ * Description:
+ *   Warnings for back-to-back meetings in buildings too
+ *   far apart to walk between in the gap. Walking times
+ *   come from BuildingDistances in config; pairs missing
+ *   from it are estimated from the coordinates of the
+ *   building registry. Pairs with neither are skipped.
+ */
+package api
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+
+	"wilkesu-scrapy/config"
+)
+
+// Pace of the estimates, a slow walk with room for stairs and corners
+const (
+	walkingMetersPerMinute = 70
+	walkingDetour          = 1.3 // Paths are longer than the straight line
+	earthRadiusMeters      = 6371000
+)
+
+// Walking minutes of config between building codes, read once
+var walkingTable = sync.OnceValue(func() map[string]map[string]int {
+	return buildWalkingTable(config.LoadConfig().BuildingDistances)
+})
+
+/*
+ * Build the walking table of BuildingDistances. One direction of
+ * a pair is enough; it is used for the other direction only when
+ * that one is not configured itself (uphill may take longer).
+ */
+func buildWalkingTable(distances map[string]map[string]int) map[string]map[string]int {
+	table := map[string]map[string]int{}
+	set := func(from string, to string, minutes int) {
+		if table[from] == nil {
+			table[from] = map[string]int{}
+		}
+		table[from][to] = minutes
+	}
+	for from, row := range distances {
+		for to, minutes := range row {
+			set(strings.ToUpper(from), strings.ToUpper(to), minutes)
+		}
+	}
+	for from, row := range distances {
+		for to, minutes := range row {
+			from, to := strings.ToUpper(from), strings.ToUpper(to)
+			if _, configured := table[to][from]; !configured {
+				set(to, from, minutes)
+			}
+		}
+	}
+	return table
+}
+
+// One side of a tight transition
+type walkStop struct {
+	Crn      int    `json:"crn"`
+	Course   string `json:"course"` // CS 125 A
+	Building string `json:"building"`
+	Time     int    `json:"time"` // End of the first meeting, start of the next, minutes after midnight
+}
+
+type walkWarning struct {
+	Days        []string `json:"days"`
+	From        walkStop `json:"from"`
+	To          walkStop `json:"to"`
+	GapMinutes  int      `json:"gap_minutes"`
+	WalkMinutes int      `json:"walk_minutes"`
+}
+
+/*
+ * Return the minutes to walk between two buildings
+ * Arguments:
+ *   from : building code
+ *   to : building code
+ * Returns:
+ *   the minutes, false when neither the table nor the
+ *   coordinates of the registry cover the pair
+ */
+func walkMinutes(from string, to string) (int, bool) {
+	if from == to {
+		return 0, true
+	}
+	if minutes, ok := walkingTable()[from][to]; ok {
+		return minutes, true
+	}
+	a, okA := buildingRegistry()[from]
+	b, okB := buildingRegistry()[to]
+	if !okA || !okB || (a.Latitude == 0 && a.Longitude == 0) || (b.Latitude == 0 && b.Longitude == 0) {
+		return 0, false
+	}
+	meters := distanceMeters(a, b) * walkingDetour
+	return int(math.Ceil(meters / walkingMetersPerMinute)), true
+}
+
+/*
+ * Great-circle distance between two buildings in meters
+ */
+func distanceMeters(a config.Building, b config.Building) float64 {
+	lat1 := a.Latitude * math.Pi / 180
+	lat2 := b.Latitude * math.Pi / 180
+	dLat := lat2 - lat1
+	dLon := (b.Longitude - a.Longitude) * math.Pi / 180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusMeters * math.Asin(math.Sqrt(h))
+}
+
+/*
+ * Find the back-to-back meetings of a set of sections that
+ * leave less time than the walk between their buildings
+ * Arguments:
+ *   sections : normalised sections, expected not to overlap
+ * Returns:
+ *   one warning per pair of meetings with the days it happens on
+ */
+func walkingWarnings(sections []Course) []walkWarning {
+	type stop struct {
+		section  int
+		meeting  int
+		building string
+		start    int
+		end      int
+	}
+	byDay := map[string][]stop{}
+	for i := range sections {
+		for j, m := range sections[i].Meetings {
+			code, ok := buildingCode(m.Location)
+			if !ok || m.Start == nil || m.End == nil {
+				continue
+			}
+			for _, day := range m.Days {
+				byDay[day] = append(byDay[day], stop{section: i, meeting: j, building: code, start: *m.Start, end: *m.End})
+			}
+		}
+	}
+
+	warnings := []walkWarning{}
+	index := map[string]int{}
+	for _, d := range weekDays {
+		day := string(d)
+		stops := byDay[day]
+		slices.SortFunc(stops, func(a, b stop) int {
+			return cmp.Or(cmp.Compare(a.start, b.start), cmp.Compare(a.end, b.end))
+		})
+		for k := 1; k < len(stops); k++ {
+			a, b := stops[k-1], stops[k]
+			gap := b.start - a.end
+			// Overlapping meetings are conflicts, not walks
+			if gap < 0 {
+				continue
+			}
+			walk, ok := walkMinutes(a.building, b.building)
+			if !ok || walk <= gap {
+				continue
+			}
+			key := fmt.Sprintf("%d/%d/%d/%d", a.section, a.meeting, b.section, b.meeting)
+			if i, seen := index[key]; seen {
+				warnings[i].Days = append(warnings[i].Days, day)
+				continue
+			}
+			index[key] = len(warnings)
+			from, to := &sections[a.section], &sections[b.section]
+			warnings = append(warnings, walkWarning{
+				Days:        []string{day},
+				From:        walkStop{Crn: from.Crn, Course: sectionName(from), Building: a.building, Time: a.end},
+				To:          walkStop{Crn: to.Crn, Course: sectionName(to), Building: b.building, Time: b.start},
+				GapMinutes:  gap,
+				WalkMinutes: walk,
+			})
+		}
+	}
+	return warnings
+}
@@ -10,24 +10,6 @@ export default function Card_Grid({searchState, filterVisible}) {
 
   }
 
-  const validateBySearch = (course) => {
-    const value = searchState.value.toLowerCase()
-
-    return ((course.course_category ? String(course.course_category) + " " + String(course.course_id)  : '').toLowerCase().includes(value) ||
-            (course.instructor ? String(course.instructor) : '').toLowerCase().includes(value) ||
-            (course.crn ? String(course.crn) : '').includes(value) ||
-            (course.location ? String(course.location) : '').toLowerCase().includes(value) ||
-            (course.title ? String(course.title) : '').toLowerCase().includes(value) ||
-            (course.info ? String(course.info) : '').toLowerCase().includes(value))
-
-    // return (String(course.course_category).toLowerCase().includes(value) ||
-    //         String(course.instructor).toLowerCase().includes(value) ||
-    //         String(course.crn).includes(value) ||
-    //         String(course.location).toLowerCase().includes(value) ||
-    //         String(course.title).toLowerCase().includes(value) ||
-    //         String(course.info).toLowerCase().includes(value))
-  }
-
   const GridWidth = {
     maxWidth: filterVisible ? "calc(100vw - 500px - 10px)" : "100vw"
   }
@@ -56,13 +38,19 @@ export default function Card_Grid({searchState, filterVisible}) {
           params.append(key, value);
         }
       }
-      const url = `http://localhost:8080/filter?${params.toString()}`;
+      let url = `http://localhost:8080/filter?${params.toString()}`;
+
+      // Free text searches are ranked by the server
+      if (searchState?.mode === "search" && searchState.value.trim() !== "") {
+        const searchParams = new URLSearchParams({semester: serverFilter.semester, q: searchState.value});
+        url = `http://localhost:8080/search?${searchParams.toString()}`;
+      }
 
       let courses;
       fetch(url)
         .then(response => response.json())
-        .then(data => {
-          const courses = data;
+        .then(body => {
+          const courses = body.data;
           let tmpCards = [];
           const addCard = (course) => {
             const category = course.course_category;
@@ -85,7 +73,7 @@ export default function Card_Grid({searchState, filterVisible}) {
           };
 
           if (searchState?.mode === "search") {
-            courses.filter(validateBySearch).forEach(addCard);
+            courses.forEach(addCard);
           } else if (searchState?.mode === "filter") {
             courses.filter(validateByFilter).forEach(addCard);
           } else {
@@ -25,11 +25,42 @@ import (
 	"encoding/json"
 	"os"
 	"log"
+	"log/slog"
+	"time"
 )
 
 type Configuration struct {
 	ConfigPath   string   `json:"ConfigPath"`
 	MongoUri     string   `json:"MongoUri"`
+	LogLevel     string   `json:"LogLevel"` // DEBUG; INFO; WARN; ERROR
+	TracePath        string   `json:"TracePath"` // runtime/trace output of a scrape, empty disables tracing
+	CPUProfilePath   string   `json:"CPUProfilePath"` // CPU profile of a scrape, empty disables it
+	HeapProfilePath  string   `json:"HeapProfilePath"` // Heap profile written after a scrape, empty disables it
+	EnablePprof      bool     `json:"EnablePprof"` // Serve /debug/pprof/ on the API server
+	ReadyMaxScrapeAge string  `json:"ReadyMaxScrapeAge"` // /readyz fails once the last scrape is older, e.g. 168h
+	TermDates    map[string]TermDates `json:"TermDates"` // First and last day of classes of each term, e.g. F25
+	Buildings    map[string]Building  `json:"Buildings"` // Building of each location code, e.g. SLC
+	BuildingDistances map[string]map[string]int `json:"BuildingDistances"` // Walking minutes between building codes, one direction is enough
+	Subjects     map[string]Subject   `json:"Subjects"` // Department of each subject code, e.g. CS
+}
+
+// The department offering a subject
+type Subject struct {
+	Name   string `json:"Name"`
+	School string `json:"School"`
+}
+
+// A campus building the roster refers to by its code
+type Building struct {
+	Name      string  `json:"Name"`
+	Latitude  float64 `json:"Latitude"`
+	Longitude float64 `json:"Longitude"`
+}
+
+// First and last day of classes, as YYYY-MM-DD
+type TermDates struct {
+	Start string `json:"Start"`
+	End   string `json:"End"`
 }
 
 /*
@@ -47,6 +78,28 @@ func createDefault(path string) {
 	defaultConfig, err := json.Marshal(Configuration{
 		ConfigPath:  "config/config.json",
 		MongoUri:    "mongodb://mongodb:27017",
+		LogLevel:    "INFO",
+		TracePath:       "",
+		CPUProfilePath:  "",
+		HeapProfilePath: "",
+		EnablePprof:     false,
+		ReadyMaxScrapeAge: "168h",
+		TermDates: map[string]TermDates{
+			"F25": {Start: "2025-08-25", End: "2025-12-12"},
+		},
+		Buildings: map[string]Building{
+			"BREIS": {Name: "Breiseth Hall", Latitude: 41.2436, Longitude: -75.8870},
+			"DDD":   {Name: "Dorothy Dickson Darte Center", Latitude: 41.2425, Longitude: -75.8866},
+			"SLC":   {Name: "Stark Learning Center", Latitude: 41.2441, Longitude: -75.8890},
+		},
+		BuildingDistances: map[string]map[string]int{
+			"SLC": {"BREIS": 4, "DDD": 5},
+		},
+		Subjects: map[string]Subject{
+			"CS":  {Name: "Computer Science", School: "College of Science and Engineering"},
+			"ENG": {Name: "English", School: "College of Arts, Humanities and Social Sciences"},
+			"MTH": {Name: "Mathematics", School: "College of Science and Engineering"},
+		},
 	})
 	if err != nil {
 		log.Fatal("config.go: ", err)
@@ -84,6 +137,54 @@ func LoadConfig() (Configuration) {
 	return configuration
 }
 
+/*
+ * Parse LogLevel into a slog level, falling back
+ * to INFO when it is empty or unknown
+ */
+func (c Configuration) SlogLevel() slog.Level {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		return slog.LevelInfo
+	}
+	return level
+}
+
+/*
+ * Parse ReadyMaxScrapeAge, falling back to one
+ * week when it is empty or not a valid duration
+ */
+func (c Configuration) MaxScrapeAge() time.Duration {
+	age, err := time.ParseDuration(c.ReadyMaxScrapeAge)
+	if err != nil || age <= 0 {
+		return 7 * 24 * time.Hour
+	}
+	return age
+}
+
+/*
+ * Return the first and last day of classes of a term
+ * Arguments:
+ *   term : term name (e.g.: F25)
+ * Returns:
+ *   the days at midnight, false if the term has no
+ *   valid dates in TermDates
+ */
+func (c Configuration) TermRange(term string) (time.Time, time.Time, bool) {
+	dates, ok := c.TermDates[term]
+	if !ok {
+		return time.Time{}, time.Time{}, false
+	}
+	start, err := time.Parse(time.DateOnly, dates.Start)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	end, err := time.Parse(time.DateOnly, dates.End)
+	if err != nil || end.Before(start) {
+		return time.Time{}, time.Time{}, false
+	}
+	return start, end, true
+}
+
 // For testing purposes only
 func main() {
 	fmt.Println("Loading config")
@@ -9,9 +9,12 @@ package db
 import (
 	"fmt"
 	"log"
+	"log/slog"
 	"context"
+	"time"
 
 	"wilkesu-scrapy/config"
+	"wilkesu-scrapy/metrics"
 
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
@@ -22,27 +25,45 @@ import (
  * Connect to MongoDB instance and return pointer to client
  */
 func Connect() (*mongo.Client) {
-	log.Println("Establishing MongoDB connection ...")
+	slog.Info("Establishing MongoDB connection")
 	var mongoClient *mongo.Client
 	config := config.LoadConfig()
 	uri := config.MongoUri // "mongodb://mongodb:27017"
 	serverAPI := options.ServerAPI(options.ServerAPIVersion1) // "1" is currently the only API version available
-	opts := options.Client().ApplyURI(uri).SetServerAPIOptions(serverAPI)
+	opts := options.Client().ApplyURI(uri).SetServerAPIOptions(serverAPI).SetMonitor(metrics.MongoMonitor())
 	mongoClient, err := mongo.Connect(opts)
 	if err != nil {
 		log.Fatal("config.go: ", err)
 	}
-	log.Println("MongoDB client initialized")
+	slog.Info("MongoDB client initialized")
 	return mongoClient
 }
 
+/*
+ * Disconnect from MongoDB instance, giving pending
+ * operations up to timeout to finish
+ * Arguments:
+ *   mongoClient : client returned by Connect
+ *   timeout : how long to wait before forcing the disconnect
+ */
+func Disconnect(mongoClient *mongo.Client, timeout time.Duration) {
+	slog.Info("Closing MongoDB connection")
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := mongoClient.Disconnect(ctx); err != nil {
+		slog.Error("Failed to disconnect MongoDB client", "err", err)
+		return
+	}
+	slog.Info("MongoDB client disconnected")
+}
+
 // For testing purposes only
 func main() {
 	mongoClient := Connect()
 
 	// Ping the Database for verification
 	var result bson.M
-	if err := mongoClient.Database("admin").RunCommand(context.TODO(), bson.D{{"ping", 1}}).Decode(&result); err != nil {
+	if err := mongoClient.Database("admin").RunCommand(context.TODO(), bson.D{{Key: "ping", Value: 1}}).Decode(&result); err != nil {
 		fmt.Println("Something went wrong with pinging")
 		panic(err)
 	}
@@ -1,27 +1,56 @@
- package main
+package main
 
 import (
+	"context"
+	"log/slog"
 	"os"
+	"os/signal"
 	"sync"
+	"syscall"
+	"time"
 	"wilkesu-scrapy/api"
+	"wilkesu-scrapy/config"
+	"wilkesu-scrapy/db"
 	"wilkesu-scrapy/scraper"
 )
 
 func main() {
+	// Structured logging, token level tracing only shows at DEBUG
+	cfg := config.LoadConfig()
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+		Level: cfg.SlogLevel(),
+	})))
+
+	// Cancelled on SIGINT / SIGTERM (e.g. docker compose down)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Connect to MongoDB instance before anything can use it
+	mongoClient := db.Connect()
+	api.SetClient(mongoClient)
+
 	var wg sync.WaitGroup
 	wg.Add(1)
 
 	// Serve on :8080
 	go func() {
 		defer wg.Done()
-		api.Serve()
+		if err := api.Serve(ctx); err != nil {
+			slog.Error("API server failed", "err", err)
+			stop()
+		}
 	}()
 
 	// if os.Args[0] == "-s" {
 	os.Args = []string{"F", "25"}
-	scraper.Scraper()
+	if err := scraper.Scraper(ctx); err != nil {
+		slog.Error("Scraper failed", "err", err)
+	}
 	// }
 
 	// Wait for process to finish
 	wg.Wait()
+
+	db.Disconnect(mongoClient, 10*time.Second)
+	slog.Info("Shutdown complete")
 }
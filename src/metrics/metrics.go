@@ -0,0 +1,127 @@
+/*
+ * file: metrics.go
+ * Description:
+ *   Prometheus collectors shared by the scraper and
+ *   the API. Everything is registered on the default
+ *   registry and exposed by Handler on /metrics.
+ *
+ *   NOTE: Keep label values to a fixed set (terms, routes,
+ *   field names, command names) so series do not grow
+ *   with user input.
+ */
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"go.mongodb.org/mongo-driver/v2/event"
+)
+
+var (
+	ScrapeDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "scraper_duration_seconds",
+		Help:    "Time taken by a full scrape of a term.",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 10),
+	}, []string{"term", "outcome"})
+
+	RowsParsed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "scraper_rows_parsed_total",
+		Help: "Table rows parsed into courses or course children.",
+	}, []string{"term"})
+
+	ParseErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "scraper_parse_errors_total",
+		Help: "Errors returned by each field function while parsing.",
+	}, []string{"field"})
+
+	BadChunkRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "scraper_bad_chunk_retries_total",
+		Help: "Times the body was rechunked because a chunk started on a course child.",
+	}, []string{"term"})
+
+	CoursesInserted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "scraper_courses_inserted_total",
+		Help: "Courses written to the database by the inserters.",
+	}, []string{"term"})
+
+	InsertBatchDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "scraper_insert_batch_duration_seconds",
+		Help:    "Time taken to write one batch of courses.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"term", "outcome"})
+
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Latency of API requests by route, method and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	MongoCommandDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "mongo_command_duration_seconds",
+		Help:    "Latency of MongoDB commands by command name and outcome.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"command", "outcome"})
+)
+
+/*
+ * Handler serving every registered collector
+ * in the Prometheus text format
+ */
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// statusRecorder remembers the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+/*
+ * Wrap a ServeMux so every request records its latency under
+ * the pattern it matched rather than the raw path
+ * Arguments:
+ *   mux : the mux serving the routes
+ */
+func InstrumentMux(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		mux.ServeHTTP(rec, r)
+
+		// ServeMux sets Pattern on the request it was given
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		HTTPRequestDuration.WithLabelValues(route, r.Method, strconv.Itoa(rec.status)).
+			Observe(time.Since(start).Seconds())
+	})
+}
+
+/*
+ * Command monitor for the MongoDB client options
+ * recording the latency of every command
+ */
+func MongoMonitor() *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			MongoCommandDuration.WithLabelValues(e.CommandName, "success").Observe(e.Duration.Seconds())
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			MongoCommandDuration.WithLabelValues(e.CommandName, "failure").Observe(e.Duration.Seconds())
+		},
+	}
+}
@@ -0,0 +1,104 @@
+package scraper
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+
+	"wilkesu-scrapy/config"
+)
+
+func startDiagnostics(cfg config.Configuration, logger *slog.Logger) (func(), error) {
+	/* startDiagnostics starts the runtime trace and CPU profile of a scrape
+	if their output paths are set in the configuration.
+
+	Nothing is written when TracePath, CPUProfilePath and HeapProfilePath
+	are all empty.
+
+	Arguments:
+		cfg (config.Configuration): The configuration holding the output paths.
+		logger (*slog.Logger): Logger carrying the term attribute.
+
+	Returns:
+		(func(), error): A function that stops tracing and profiling, writes the
+		heap profile and closes the files. Error is not nil if any of them could
+		not be started, in which case nothing is left running.
+	*/
+	stops := []func(){}
+	stop := func() {
+		for i := len(stops) - 1; i >= 0; i-- {
+			stops[i]()
+		}
+	}
+
+	if cfg.TracePath != "" {
+		f, err := os.Create(cfg.TracePath)
+		if err != nil {
+			return func() {}, err
+		}
+		if err = trace.Start(f); err != nil {
+			f.Close()
+			return func() {}, err
+		}
+		logger.Info("Runtime tracing started", "path", cfg.TracePath)
+		stops = append(stops, func() {
+			trace.Stop()
+			f.Close()
+			logger.Info("Runtime trace written", "path", cfg.TracePath)
+		})
+	}
+
+	if cfg.CPUProfilePath != "" {
+		f, err := os.Create(cfg.CPUProfilePath)
+		if err != nil {
+			stop()
+			return func() {}, err
+		}
+		if err = pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			stop()
+			return func() {}, err
+		}
+		logger.Info("CPU profiling started", "path", cfg.CPUProfilePath)
+		stops = append(stops, func() {
+			pprof.StopCPUProfile()
+			f.Close()
+			logger.Info("CPU profile written", "path", cfg.CPUProfilePath)
+		})
+	}
+
+	if cfg.HeapProfilePath != "" {
+		stops = append(stops, func() {
+			if err := writeHeapProfile(cfg.HeapProfilePath); err != nil {
+				logger.Error("Failed to write heap profile", "path", cfg.HeapProfilePath, "err", err)
+				return
+			}
+			logger.Info("Heap profile written", "path", cfg.HeapProfilePath)
+		})
+	}
+
+	return stop, nil
+}
+
+func writeHeapProfile(path string) error {
+	/* writeHeapProfile writes the current heap profile to path.
+
+	Arguments:
+		path (string): The file to write the profile to.
+
+	Returns:
+		error: Error creating or writing the file or nil
+	*/
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	// Get up-to-date statistics
+	runtime.GC()
+	err = pprof.WriteHeapProfile(f)
+	return errors.Join(err, f.Close())
+}
@@ -12,11 +12,13 @@ import (
 	"os"
 	"regexp"
 	"sync"
-	"log"
+	"log/slog"
 	"context"
 	"sync/atomic"
-	"runtime/trace"
+	"time"
 	"wilkesu-scrapy/api"
+	"wilkesu-scrapy/config"
+	"wilkesu-scrapy/metrics"
 )
 
 /* The course struct is what a course is expected to look like.
@@ -37,6 +39,7 @@ type Course struct {
 	EndTimeAMPM *string `json:"end_time_ampm,omitempty"` // AM; PM; null.
 	Location *string `json:"location,omitempty"`// SLC; BREIS; null etc.
 	RoomNum *int `json:"room_num,omitempty"` // 108, 409, any number, null etc.
+	Room *string `json:"room,omitempty"` // 108; 108A; GYM; null etc.
 	Instructor string `json:"instructor,omitempty"` // Nye B; Simpson H; Kapolka M etc.
 	Status string `json:"status,omitempty"` // Open; Nearly; Closed.
 	Limit int `json:"limit,omitempty"` // Limit to number of students
@@ -48,8 +51,18 @@ type Course struct {
 	CourseChild *Course
 }
 
+// lazyCourse defers courseToString until a debug record is actually written
+type lazyCourse Course
+
+func (c lazyCourse) LogValue() slog.Value {
+	return slog.StringValue(courseToString(Course(c)))
+}
+
+// Room number with an optional suffix: 108, 108A
+var roomPattern = regexp.MustCompile(`^(\d+)([A-Za-z]*)$`)
+
 /* Parsing functions */
-type fieldFunc func (*Course, *html.Tokenizer, *int, html.Token) error
+type fieldFunc func (*Course, *html.Tokenizer, *int, html.Token, *slog.Logger) error
 
 func courseToString(c Course) string {
 	/* courseToString takes a course and parses it into a string
@@ -101,6 +114,7 @@ func courseToString(c Course) string {
 	Additional Information:
 	- Location: %s
 	- Room Number: %s
+	- Room: %s
 	- Online: %t
 	- Special Info: %s`,
         c.CourseId,
@@ -121,6 +135,7 @@ func courseToString(c Course) string {
         c.Waiting,
         safeString(c.Location),
         safeInt(c.RoomNum),
+        safeString(c.Room),
         c.IsOnline,
         safeString(c.Info))
 
@@ -135,7 +150,7 @@ func courseToString(c Course) string {
     return description
 }
 
-func getDeliveryMode(c *Course, tokenizer *html.Tokenizer, fieldCount *int, startToken html.Token) error {
+func getDeliveryMode(c *Course, tokenizer *html.Tokenizer, fieldCount *int, startToken html.Token, logger *slog.Logger) error {
 	/* getDeliveryMode gets the delivery mode from the course.
 
 	Delivery modes is how the course is offered (F2F, HYB, OL etc.)
@@ -145,6 +160,7 @@ func getDeliveryMode(c *Course, tokenizer *html.Tokenizer, fieldCount *int, star
 		tokenizer (*html.Tokenizer): The tokenizer to use to get the data.
 		fieldCount (*int): The current field the parser is on.
 		startToken (html.Token): The current token this field is starting on.
+		logger (*slog.Logger): Logger carrying the worker and term attributes.
 	
 	Returns:
 		error: Error during parsing or nil 
@@ -154,19 +170,19 @@ func getDeliveryMode(c *Course, tokenizer *html.Tokenizer, fieldCount *int, star
 		tokenType := tokenizer.Next()
 		token := tokenizer.Token()
 		if ((tokenType == html.ErrorToken) || ((tokenType == html.EndTagToken) && (token.Data == "td")))  {
-			fmt.Println("End of Delivery Mode field, exiting . . .")
+			logger.Debug("End of Delivery Mode field")
 			break
 		}
 
 		if (tokenType == html.TextToken) {
-			fmt.Printf("Delivery Mode Token found: %s\n", token.Data)
+			logger.Debug("Delivery Mode Token found", "data", token.Data)
 			c.DeliveryMode = token.Data
 		}
 	}
 	return nil
 }
 
-func getCourseCategoryAndId(c *Course, tokenizer *html.Tokenizer, fieldCount *int, startToken html.Token) error {
+func getCourseCategoryAndId(c *Course, tokenizer *html.Tokenizer, fieldCount *int, startToken html.Token, logger *slog.Logger) error {
 	/* getCourseCategoryAndId gets the course category 
 	and id of the course.
 
@@ -178,6 +194,7 @@ func getCourseCategoryAndId(c *Course, tokenizer *html.Tokenizer, fieldCount *in
 		tokenizer (*html.Tokenizer): The tokenizer to use to get the data.
 		fieldCount (*int): The current field the parser is on.
 		startToken (html.Token): The current token this field is starting on.
+		logger (*slog.Logger): Logger carrying the worker and term attributes.
 	
 	Returns:
 		error: Error during parsing or nil 
@@ -186,12 +203,12 @@ func getCourseCategoryAndId(c *Course, tokenizer *html.Tokenizer, fieldCount *in
 		tokenType := tokenizer.Next()
 		token := tokenizer.Token()
 		if ((tokenType == html.ErrorToken) || ((tokenType == html.EndTagToken) && (token.Data == "td")))  {
-			fmt.Println("End of Course Category field, exiting . . .")
+			logger.Debug("End of Course Category field")
 			break
 		}
 
 		if (tokenType == html.TextToken) {
-			fmt.Printf("Course Category and Id found: %s\n", token.Data)
+			logger.Debug("Course Category and Id found", "data", token.Data)
 			splitData := strings.Split(token.Data, " ");
 			if (len(splitData) != 2) {
 				return errors.New(fmt.Sprintf("Course category and id in unexpected format." + 
@@ -208,7 +225,7 @@ func getCourseCategoryAndId(c *Course, tokenizer *html.Tokenizer, fieldCount *in
 	return nil
 }
 
-func getSection(c *Course, tokenizer *html.Tokenizer, fieldCount *int, startToken html.Token) error {
+func getSection(c *Course, tokenizer *html.Tokenizer, fieldCount *int, startToken html.Token, logger *slog.Logger) error {
 	/* getSection gets the section from the course.
 
 	Section is the group of the Section (A, B, INA etc.)
@@ -218,6 +235,7 @@ func getSection(c *Course, tokenizer *html.Tokenizer, fieldCount *int, startToke
 		tokenizer (*html.Tokenizer): The tokenizer to use to get the data.
 		fieldCount (*int): The current field the parser is on.
 		startToken (html.Token): The current token this field is starting on.
+		logger (*slog.Logger): Logger carrying the worker and term attributes.
 	
 	Returns:
 		error: Error during parsing or nil 
@@ -227,19 +245,19 @@ func getSection(c *Course, tokenizer *html.Tokenizer, fieldCount *int, startToke
 		tokenType := tokenizer.Next()
 		token := tokenizer.Token()
 		if ((tokenType == html.ErrorToken) || ((tokenType == html.EndTagToken) && (token.Data == "td")))  {
-			fmt.Println("End of Section field, exiting . . .")
+			logger.Debug("End of Section field")
 			break
 		}
 
 		if (tokenType == html.TextToken) {
-			fmt.Printf("Section Token found: %s\n", token.Data)
+			logger.Debug("Section Token found", "data", token.Data)
 			c.Section = token.Data
 		}
 	}
 	return nil
 }
 
-func getCRN(c *Course, tokenizer *html.Tokenizer, fieldCount *int,  startToken html.Token) error {
+func getCRN(c *Course, tokenizer *html.Tokenizer, fieldCount *int,  startToken html.Token, logger *slog.Logger) error {
 	/* getCRN gets the CRN from the course.
 
 	CRN is the course registration number (31233,23213, 0 etc.)
@@ -249,6 +267,7 @@ func getCRN(c *Course, tokenizer *html.Tokenizer, fieldCount *int,  startToken h
 		tokenizer (*html.Tokenizer): The tokenizer to use to get the data.
 		fieldCount (*int): The current field the parser is on.
 		startToken (html.Token): The current token this field is starting on.
+		logger (*slog.Logger): Logger carrying the worker and term attributes.
 	
 	Returns:
 		error: Error during parsing or nil 
@@ -258,12 +277,12 @@ func getCRN(c *Course, tokenizer *html.Tokenizer, fieldCount *int,  startToken h
 		tokenType := tokenizer.Next()
 		token := tokenizer.Token()
 		if ((tokenType == html.ErrorToken) || ((tokenType == html.EndTagToken) && (token.Data == "td")))  {
-			fmt.Println("End of CRN field, exiting . . .")
+			logger.Debug("End of CRN field")
 			break
 		}
 
 		if (tokenType == html.TextToken) {
-			fmt.Printf("CRN Token found: %s\n", token.Data)
+			logger.Debug("CRN Token found", "data", token.Data)
 			parsedCRN, err := strconv.Atoi(token.Data)
 			if err != nil {
 				return err
@@ -274,7 +293,7 @@ func getCRN(c *Course, tokenizer *html.Tokenizer, fieldCount *int,  startToken h
 	return nil
 }
 
-func getTitle(c *Course, tokenizer *html.Tokenizer, fieldCount *int,  startToken html.Token) error {
+func getTitle(c *Course, tokenizer *html.Tokenizer, fieldCount *int,  startToken html.Token, logger *slog.Logger) error {
 	/* getTitle gets the name from the course.
 
 	Arguments:
@@ -282,6 +301,7 @@ func getTitle(c *Course, tokenizer *html.Tokenizer, fieldCount *int,  startToken
 		tokenizer (*html.Tokenizer): The tokenizer to use to get the data.
 		fieldCount (*int): The current field the parser is on.
 		startToken (html.Token): The current token this field is starting on.
+		logger (*slog.Logger): Logger carrying the worker and term attributes.
 	
 	Returns:
 		error: Error during parsing or nil 
@@ -291,19 +311,19 @@ func getTitle(c *Course, tokenizer *html.Tokenizer, fieldCount *int,  startToken
 		tokenType := tokenizer.Next()
 		token := tokenizer.Token()
 		if ((tokenType == html.ErrorToken) || ((tokenType == html.EndTagToken) && (token.Data == "td")))  {
-			fmt.Println("End of Title field, exiting . . .")
+			logger.Debug("End of Title field")
 			break
 		}
 
 		if (tokenType == html.TextToken) {
-			fmt.Printf("Title Token found: %s\n", token.Data)
+			logger.Debug("Title Token found", "data", token.Data)
 			c.Title = token.Data
 		}
 	}
 	return nil
 }
 
-func getCredits(c *Course, tokenizer *html.Tokenizer, fieldCount *int, startToken html.Token) error {
+func getCredits(c *Course, tokenizer *html.Tokenizer, fieldCount *int, startToken html.Token, logger *slog.Logger) error {
 	/* getCredits gets the credits from the course.
 
 	Credits are floats (3.00, 4.00, 1.00, etc.)
@@ -313,6 +333,7 @@ func getCredits(c *Course, tokenizer *html.Tokenizer, fieldCount *int, startToke
 		tokenizer (*html.Tokenizer): The tokenizer to use to get the data.
 		fieldCount (*int): The current field the parser is on.
 		startToken (html.Token): The current token this field is starting on.
+		logger (*slog.Logger): Logger carrying the worker and term attributes.
 	
 	Returns:
 		error: Error during parsing or nil 
@@ -322,12 +343,12 @@ func getCredits(c *Course, tokenizer *html.Tokenizer, fieldCount *int, startToke
 		tokenType := tokenizer.Next()
 		token := tokenizer.Token()
 		if ((tokenType == html.ErrorToken) || ((tokenType == html.EndTagToken) && (token.Data == "td")))  {
-			fmt.Println("End of Credits field, exiting . . .")
+			logger.Debug("End of Credits field")
 			break
 		}
 
 		if (tokenType == html.TextToken) {
-			fmt.Printf("Credits Token found: %s\n", token.Data)
+			logger.Debug("Credits Token found", "data", token.Data)
 			parsedCredits, err := strconv.ParseFloat(token.Data, 32)
 			if err != nil {
 				return err
@@ -338,7 +359,7 @@ func getCredits(c *Course, tokenizer *html.Tokenizer, fieldCount *int, startToke
 	return nil
 }
 
-func getDay(c *Course, tokenizer *html.Tokenizer, fieldCount *int,  startToken html.Token) error {
+func getDay(c *Course, tokenizer *html.Tokenizer, fieldCount *int,  startToken html.Token, logger *slog.Logger) error {
 	/* getDay gets the days given from the course.
 
 	Days are formated as TR, MWF, WF, R etc.
@@ -348,6 +369,7 @@ func getDay(c *Course, tokenizer *html.Tokenizer, fieldCount *int,  startToken h
 		tokenizer (*html.Tokenizer): The tokenizer to use to get the data.
 		fieldCount (*int): The current field the parser is on.
 		startToken (html.Token): The current token this field is starting on.
+		logger (*slog.Logger): Logger carrying the worker and term attributes.
 	
 	Returns:
 		error: Error during parsing or nil 
@@ -359,7 +381,7 @@ func getDay(c *Course, tokenizer *html.Tokenizer, fieldCount *int,  startToken h
 		if (attr.Key == "colspan" && attr.Val == "3") {
 
 			// Move tokenzier to the next row
-			fmt.Println("Day, Time, and Location unknow . . . skipping to instructor")
+			logger.Debug("Day, Time, and Location unknown, skipping to instructor")
 			tokenizer.Next()
 			t := tokenizer.Token()
 			c.Location = &t.Data
@@ -373,19 +395,19 @@ func getDay(c *Course, tokenizer *html.Tokenizer, fieldCount *int,  startToken h
 		tokenType := tokenizer.Next()
 		token := tokenizer.Token()
 		if ((tokenType == html.ErrorToken) || ((tokenType == html.EndTagToken) && (token.Data == "td")))  {
-			fmt.Println("End of Day field, exiting . . .")
+			logger.Debug("End of Day field")
 			break
 		}
 
 		if (tokenType == html.TextToken) {
-			fmt.Printf("Day Token found: %s\n", token.Data)
+			logger.Debug("Day Token found", "data", token.Data)
 			c.Day = &token.Data
 		}
 	}
 	return nil
 }
 
-func getTime(c *Course, tokenizer *html.Tokenizer, fieldCount *int,  startToken html.Token) error {
+func getTime(c *Course, tokenizer *html.Tokenizer, fieldCount *int,  startToken html.Token, logger *slog.Logger) error {
 	/* getTime gets the time given from the course.
 
 	Course time is formatted as such:
@@ -401,6 +423,7 @@ func getTime(c *Course, tokenizer *html.Tokenizer, fieldCount *int,  startToken
 		tokenizer (*html.Tokenizer): The tokenizer to use to get the data.
 		fieldCount (*int): The current field the parser is on.
 		startToken (html.Token): The current token this field is starting on.
+		logger (*slog.Logger): Logger carrying the worker and term attributes.
 	
 	Returns:
 		error: Error during parsing or nil 
@@ -409,12 +432,12 @@ func getTime(c *Course, tokenizer *html.Tokenizer, fieldCount *int,  startToken
 		tokenType := tokenizer.Next()
 		token := tokenizer.Token()
 		if ((tokenType == html.ErrorToken) || ((tokenType == html.EndTagToken) && (token.Data == "td")))  {
-			fmt.Println("End of Time field, exiting . . .")
+			logger.Debug("End of Time field")
 			break
 		}
 
 		if (tokenType == html.TextToken) {
-			fmt.Printf("Time Token found: %s\n", token.Data)
+			logger.Debug("Time Token found", "data", token.Data)
 
 			timeFormat := "^([0-9]{4}-[0-9]{4})(?:AM|PM)$"
 			re := regexp.MustCompile(timeFormat)
@@ -447,7 +470,7 @@ func getTime(c *Course, tokenizer *html.Tokenizer, fieldCount *int,  startToken
 	return nil
 }
 
-func getLocation(c *Course, tokenizer *html.Tokenizer, fieldCount *int,  startToken html.Token) error {
+func getLocation(c *Course, tokenizer *html.Tokenizer, fieldCount *int,  startToken html.Token, logger *slog.Logger) error {
 	/* getLocation gets the location and the room number 
 	of the course
 
@@ -459,41 +482,45 @@ func getLocation(c *Course, tokenizer *html.Tokenizer, fieldCount *int,  startTo
 		tokenizer (*html.Tokenizer): The tokenizer to use to get the data.
 		fieldCount (*int): The current field the parser is on.
 		startToken (html.Token): The current token this field is starting on.
+		logger (*slog.Logger): Logger carrying the worker and term attributes.
 	
 	Returns:
 		error: Error during parsing or nil 
 	*/
 	if (c.DeliveryMode == "SOL") {
-		fmt.Printf("Course Location found: Online\n")
+		logger.Debug("Course Location found", "data", "Online")
 		output := "Online"
 		c.Location = &output 
-		fmt.Println("End of Location field, exiting . . .")
+		logger.Debug("End of Location field")
 		return nil
 	}
 	for {
 		tokenType := tokenizer.Next()
 		token := tokenizer.Token()
 		if ((tokenType == html.ErrorToken) || ((tokenType == html.EndTagToken) && (token.Data == "td")))  {
-			fmt.Println("End of Location field, exiting . . .")
+			logger.Debug("End of Location field")
 			break
 		}
 
 		if (tokenType == html.TextToken) {
-			fmt.Printf("Course Location found: %s\n", token.Data)
+			logger.Debug("Course Location found", "data", token.Data)
 			if (token.Data == "TBA") {
 				c.Location = &token.Data
 			} else {
-				splitData := strings.Split(token.Data, " ");
+				splitData := strings.Fields(token.Data)
 				if (len(splitData) != 2) {
 					return errors.New(fmt.Sprintf("Course Location in unexpected format." + 
 									   " Got %d; Expected 2.", len(splitData)))
 				}
 				c.Location = &splitData[0]
-				roomNum, err := strconv.Atoi(splitData[1])
-				if err != nil {
-					c.Location = &token.Data
-				} else {
-					c.RoomNum = &roomNum
+				c.Room = &splitData[1]
+				// Room numbers may carry a suffix (108A), RoomNum keeps the number
+				match := roomPattern.FindStringSubmatch(splitData[1])
+				if match != nil {
+					roomNum, err := strconv.Atoi(match[1])
+					if err == nil {
+						c.RoomNum = &roomNum
+					}
 				}
 			}
 		}
@@ -501,7 +528,7 @@ func getLocation(c *Course, tokenizer *html.Tokenizer, fieldCount *int,  startTo
 	return nil
 }
 
-func getInstructor(c *Course, tokenizer *html.Tokenizer, fieldCount *int,  startToken html.Token) error {
+func getInstructor(c *Course, tokenizer *html.Tokenizer, fieldCount *int,  startToken html.Token, logger *slog.Logger) error {
 	/* getInstructor gets the instructor from the course.
 
 	Arguments:
@@ -509,6 +536,7 @@ func getInstructor(c *Course, tokenizer *html.Tokenizer, fieldCount *int,  start
 		tokenizer (*html.Tokenizer): The tokenizer to use to get the data.
 		fieldCount (*int): The current field the parser is on.
 		startToken (html.Token): The current token this field is starting on.
+		logger (*slog.Logger): Logger carrying the worker and term attributes.
 	
 	Returns:
 		error: Error during parsing or nil 
@@ -518,19 +546,19 @@ func getInstructor(c *Course, tokenizer *html.Tokenizer, fieldCount *int,  start
 		tokenType := tokenizer.Next()
 		token := tokenizer.Token()
 		if ((tokenType == html.ErrorToken) || ((tokenType == html.EndTagToken) && (token.Data == "td")))  {
-			fmt.Println("End of Instructor field, exiting . . .")
+			logger.Debug("End of Instructor field")
 			break
 		}
 
 		if (tokenType == html.TextToken) {
-			fmt.Printf("Instructor Token found: %s\n", token.Data)
+			logger.Debug("Instructor Token found", "data", token.Data)
 			c.Instructor = token.Data
 		}
 	}
 	return nil
 }
 
-func getStatus(c *Course, tokenizer *html.Tokenizer, fieldCount *int,  startToken html.Token) error {
+func getStatus(c *Course, tokenizer *html.Tokenizer, fieldCount *int,  startToken html.Token, logger *slog.Logger) error {
 	/* getStatus gets the status from the course.
 
 	Course status is how full the course is (Nearly, Closed, Open)
@@ -540,6 +568,7 @@ func getStatus(c *Course, tokenizer *html.Tokenizer, fieldCount *int,  startToke
 		tokenizer (*html.Tokenizer): The tokenizer to use to get the data.
 		fieldCount (*int): The current field the parser is on.
 		startToken (html.Token): The current token this field is starting on.
+		logger (*slog.Logger): Logger carrying the worker and term attributes.
 	
 	Returns:
 		error: Error during parsing or nil 
@@ -550,19 +579,19 @@ func getStatus(c *Course, tokenizer *html.Tokenizer, fieldCount *int,  startToke
 		tokenType := tokenizer.Next()
 		token := tokenizer.Token()
 		if ((tokenType == html.ErrorToken) || ((tokenType == html.EndTagToken) && (token.Data == "td")))  {
-			fmt.Println("End of Status field, exiting . . .")
+			logger.Debug("End of Status field")
 			break
 		}
 
 		if (tokenType == html.TextToken && textTokenCount == 0) {
 
-			fmt.Printf("Status Token found: %s\n", token.Data)
+			logger.Debug("Status Token found", "data", token.Data)
 			c.Status = token.Data
 			textTokenCount++
 
 		} else if (tokenType == html.TextToken && textTokenCount == 1) {
 
-			fmt.Printf("Limit Token found: %s\n", token.Data)
+			logger.Debug("Limit Token found", "data", token.Data)
 			limit, err := strconv.Atoi(token.Data)
 			if err != nil {
 				return err
@@ -574,7 +603,7 @@ func getStatus(c *Course, tokenizer *html.Tokenizer, fieldCount *int,  startToke
 	return nil
 }
 
-func getStudents(c *Course, tokenizer *html.Tokenizer, fieldCount *int,  startToken html.Token) error {
+func getStudents(c *Course, tokenizer *html.Tokenizer, fieldCount *int,  startToken html.Token, logger *slog.Logger) error {
 	/* getStudents gets the number of students in the course.
 
 	Arguments:
@@ -582,6 +611,7 @@ func getStudents(c *Course, tokenizer *html.Tokenizer, fieldCount *int,  startTo
 		tokenizer (*html.Tokenizer): The tokenizer to use to get the data.
 		fieldCount (*int): The current field the parser is on.
 		startToken (html.Token): The current token this field is starting on.
+		logger (*slog.Logger): Logger carrying the worker and term attributes.
 	
 	Returns:
 		error: Error during parsing or nil 
@@ -591,12 +621,12 @@ func getStudents(c *Course, tokenizer *html.Tokenizer, fieldCount *int,  startTo
 		tokenType := tokenizer.Next()
 		token := tokenizer.Token()
 		if ((tokenType == html.ErrorToken) || ((tokenType == html.EndTagToken) && (token.Data == "td")))  {
-			fmt.Println("End of Students field, exiting . . .")
+			logger.Debug("End of Students field")
 			break
 		}
 
 		if (tokenType == html.TextToken) {
-			fmt.Printf("Student Token found: %s\n", token.Data)
+			logger.Debug("Student Token found", "data", token.Data)
 			parsedStudents, err := strconv.Atoi(token.Data)
 			if err != nil {
 				return err
@@ -607,7 +637,7 @@ func getStudents(c *Course, tokenizer *html.Tokenizer, fieldCount *int,  startTo
 	return nil
 }
 
-func getWaiting(c *Course, tokenizer *html.Tokenizer, fieldCount *int,  startToken html.Token) error {
+func getWaiting(c *Course, tokenizer *html.Tokenizer, fieldCount *int,  startToken html.Token, logger *slog.Logger) error {
 	/* getStudents gets the number of students waiting in the course.
 
 	Arguments:
@@ -615,6 +645,7 @@ func getWaiting(c *Course, tokenizer *html.Tokenizer, fieldCount *int,  startTok
 		tokenizer (*html.Tokenizer): The tokenizer to use to get the data.
 		fieldCount (*int): The current field the parser is on.
 		startToken (html.Token): The current token this field is starting on.
+		logger (*slog.Logger): Logger carrying the worker and term attributes.
 	
 	Returns:
 		error: Error during parsing or nil 
@@ -624,12 +655,12 @@ func getWaiting(c *Course, tokenizer *html.Tokenizer, fieldCount *int,  startTok
 		tokenType := tokenizer.Next()
 		token := tokenizer.Token()
 		if ((tokenType == html.ErrorToken) || ((tokenType == html.EndTagToken) && (token.Data == "td")))  {
-			fmt.Println("End of Waiting field, exiting . . .")
+			logger.Debug("End of Waiting field")
 			break
 		}
 
 		if (tokenType == html.TextToken) {
-			fmt.Printf("Waiting Token found: %s\n", token.Data)
+			logger.Debug("Waiting Token found", "data", token.Data)
 			parsedWaiting, err := strconv.Atoi(token.Data)
 			if err != nil {
 				return err
@@ -640,7 +671,7 @@ func getWaiting(c *Course, tokenizer *html.Tokenizer, fieldCount *int,  startTok
 	return nil
 }
 
-func getInfo(c *Course, tokenizer *html.Tokenizer, fieldCount *int, startToken html.Token) error {
+func getInfo(c *Course, tokenizer *html.Tokenizer, fieldCount *int, startToken html.Token, logger *slog.Logger) error {
 	/* getInfo gets info related to a previous course.
 
 	Info will look something like: HONORS STUDENTS ONLY
@@ -650,6 +681,7 @@ func getInfo(c *Course, tokenizer *html.Tokenizer, fieldCount *int, startToken h
 		tokenizer (*html.Tokenizer): The tokenizer to use to get the data.
 		fieldCount (*int): The current field the parser is on.
 		startToken (html.Token): The current token this field is starting on.
+		logger (*slog.Logger): Logger carrying the worker and term attributes.
 	
 	Returns:
 		error: Error during parsing or nil 
@@ -658,14 +690,14 @@ func getInfo(c *Course, tokenizer *html.Tokenizer, fieldCount *int, startToken h
 	for {
 		tokenType := tokenizer.Next()
 		token := tokenizer.Token()
-		fmt.Printf("Info: %s & %s \n", token.Data, tokenType)
+		logger.Debug("Info token", "data", token.Data, "type", tokenType)
 		if ((tokenType == html.ErrorToken) || ((tokenType == html.EndTagToken) && (token.Data == "td")))  {
-			fmt.Println("End of Info field, exiting . . .")
+			logger.Debug("End of Info field")
 			break
 		}
 
 		if (tokenType == html.TextToken) {
-			fmt.Printf("Info Token found: %s\n", token.Data)
+			logger.Debug("Info Token found", "data", token.Data)
 			info := token.Data
 			c.Info = &info
 		}
@@ -673,13 +705,30 @@ func getInfo(c *Course, tokenizer *html.Tokenizer, fieldCount *int, startToken h
 	return nil
 }
 
-func getField(c *Course, fieldCount *int, tokenizer *html.Tokenizer, startToken html.Token) error {
+func countParseError(field string, err error) error {
+	/* countParseError records err against field in the parse error metric.
+
+	Arguments:
+		field (string): The metric label of the field function.
+		err (error): The error returned by the field function.
+
+	Returns:
+		error: err unchanged
+	*/
+	if err != nil {
+		metrics.ParseErrors.WithLabelValues(field).Inc()
+	}
+	return err
+}
+
+func getField(c *Course, fieldCount *int, tokenizer *html.Tokenizer, startToken html.Token, logger *slog.Logger) error {
 	/* getField gets the corresponding field in a 
 	list of field functions from the given count
 
 	Arguments:
 		c (*course): The course to get the field for 
 		tokenizer (*html.Tokenizer): The tokenizer to use to get the data.
+		logger (*slog.Logger): Logger carrying the worker and term attributes.
 	
 	Returns:
 		error: Error during parsing or Error because field count is out of range
@@ -702,6 +751,23 @@ func getField(c *Course, fieldCount *int, tokenizer *html.Tokenizer, startToken
 		getWaiting,
 	}
 
+	// Metric label of each function in fieldFuncs
+	fieldNames := []string{
+		"delivery_mode",
+		"course_category_and_id",
+		"section",
+		"crn",
+		"title",
+		"credits",
+		"day",
+		"time",
+		"location",
+		"instructor",
+		"status",
+		"students",
+		"waiting",
+	}
+
 	if (c.IsCourseChild) {
 
 		var err error
@@ -721,26 +787,27 @@ func getField(c *Course, fieldCount *int, tokenizer *html.Tokenizer, startToken
 		 * <tr><td colspan=6></td><td colspan=7></td></tr> 
 		 */
 		if (hasColspan && colspanVal == 7) {
-			fmt.Printf("Course Child is extra info\n")
-			err = getInfo(c, tokenizer, fieldCount, startToken)
+			logger.Debug("Course Child is extra info")
+			err = countParseError("info", getInfo(c, tokenizer, fieldCount, startToken, logger))
 
 		/* For the case of rows that appear as:
 		 * <tr><td colspan=6></td><td>Some Data</td><td>Some Data</td><td>Some Data</td></tr> 
 		 */
 		} else {
-			fmt.Printf("Course Child is extra time\n")
-			err = getDay(c, tokenizer, fieldCount, startToken)
+			logger.Debug("Course Child is extra time")
+			err = countParseError("day", getDay(c, tokenizer, fieldCount, startToken, logger))
 			if err !=  nil { return err }
-			err = getTime(c, tokenizer, fieldCount, startToken)
+			err = countParseError("time", getTime(c, tokenizer, fieldCount, startToken, logger))
 			if err != nil { return err }
-			err = getLocation(c, tokenizer, fieldCount, startToken)
+			err = countParseError("location", getLocation(c, tokenizer, fieldCount, startToken, logger))
 			tokenizer.Next()
 		}
 
 		return err 
 
 	} else if (*fieldCount < len(fieldFuncs)) {
-		err := fieldFuncs[*fieldCount](c, tokenizer, fieldCount, startToken)
+		field := *fieldCount
+		err := countParseError(fieldNames[field], fieldFuncs[field](c, tokenizer, fieldCount, startToken, logger))
 		*fieldCount++
 		return err 
 	} else {
@@ -749,7 +816,7 @@ func getField(c *Course, fieldCount *int, tokenizer *html.Tokenizer, startToken
 	}
 }
 
-func getCourseData (tokenizer *html.Tokenizer) (Course, error) {
+func getCourseData (tokenizer *html.Tokenizer, logger *slog.Logger) (Course, error) {
 	/* getCourseData parses course data from the current table row.
 
 	It will break down the course into parts and get each field based
@@ -757,6 +824,7 @@ func getCourseData (tokenizer *html.Tokenizer) (Course, error) {
 
 	Arguments:
 		tokenizer (*html.Tokenizer): The tokenizer to use to get the data.
+		logger (*slog.Logger): Logger carrying the worker and term attributes.
 	
 	Returns:
 		course, error: the course parsed, An error that occured during parsing or nil
@@ -764,7 +832,7 @@ func getCourseData (tokenizer *html.Tokenizer) (Course, error) {
 
 	c := Course{}
 	fieldCount := 0
-	fmt.Println("Getting Course Data . . .")
+	logger.Debug("Getting Course Data")
 	for {
 		tokenType := tokenizer.Next()
 		token := tokenizer.Token()
@@ -775,7 +843,7 @@ func getCourseData (tokenizer *html.Tokenizer) (Course, error) {
 			return c, nil
 		}
 
-		fmt.Printf("In Course: Token[%s] Type[%s] fieldCount[%d]\n", token.Data, tokenType, fieldCount)
+		logger.Debug("In Course", "token", token.Data, "type", tokenType, "field_count", fieldCount)
 
 		if (tokenType == html.StartTagToken) && (token.Data == "td") {
 			// Check if <td> has attribute 'colspan' and the value of it is 6
@@ -783,10 +851,10 @@ func getCourseData (tokenizer *html.Tokenizer) (Course, error) {
 			for _, attr := range token.Attr {
 				if (attr.Key == "colspan" && fieldCount == 0) {
 					c.IsCourseChild = true
-					fmt.Println("Course Child Found")
+					logger.Debug("Course Child Found")
 				}
 			}
-			err := getField(&c, &fieldCount, tokenizer, token)
+			err := getField(&c, &fieldCount, tokenizer, token, logger)
 			if err != nil {
 				return c, errors.New(fmt.Sprintf("Error parsing course: %s", err)) 
 			}
@@ -794,21 +862,27 @@ func getCourseData (tokenizer *html.Tokenizer) (Course, error) {
 	}
 }
 
-func getHTML(link string) (string, error) {
+func getHTML(ctx context.Context, link string) (string, error) {
 	/* getHTML gets the HTML from a webpage.
 
 	Arguments:
+		ctx (context.Context): A context that aborts the request when cancelled.
 		link (string): The link to get the HTML from.
 	
 	Returns:
 		string: The HTML from the webpage.
 	*/
 
-	resp, err := http.Get(link)
-	defer resp.Body.Close()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return "", err
 	}
+	defer resp.Body.Close()
 	
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
@@ -818,21 +892,25 @@ func getHTML(link string) (string, error) {
 	return string(body), nil
 }
 
-func parseHTML(body string, workerNum int, wg *sync.WaitGroup, verifyChunk chan<- bool, dbChan chan<- Course, ctx context.Context) []Course {
+func parseHTML(body string, workerNum int, group string, wg *sync.WaitGroup, verifyChunk chan<- bool, dbChan chan<- Course, ctx context.Context, logger *slog.Logger) []Course {
 	/* parseHTML looks at a string of HTML, and tokenizes it using Golang's html tokenizer.
 
 	Arguments:
 		body (string): The body of html to parse.
 		workerNum (int): The number of this worker.
+		group (string): The group that the parsed courses are apart of.
 		wg (*sync.WaitGroup): The Wait Group this worker is apart of.
 		verifyChunk (chan<- bool): A channel to send verification if this is a good chunk.
 		dbChan (chan<- Courses): Courses will be put on this channel.
 		ctx (context.Context): A context that will ensure we stop doing work if an error occurs
+		logger (*slog.Logger): Logger carrying the term attribute, the worker number is added to it.
 
 	Returns:
 		[]Courses: A list of courses retrived.
 	*/
 	defer wg.Done()
+	logger = logger.With("worker", workerNum)
+	rowsParsed := metrics.RowsParsed.WithLabelValues(group)
 	tokenizer := html.NewTokenizer(strings.NewReader(body))
 	courses := []Course{}
 	i := -1
@@ -855,24 +933,25 @@ func parseHTML(body string, workerNum int, wg *sync.WaitGroup, verifyChunk chan<
 					// Send the last course to the DB
 					dbChan <- courses[i]
 				}
-				fmt.Printf("Worker[%d]: Sent final course to DB: %s\n", workerNum, courseToString(courses[i]))
-				fmt.Printf("Worker[%d]: Done.\n", workerNum)
+				logger.Debug("Sent final course to DB", "course", lazyCourse(courses[i]))
+				logger.Info("Worker done", "courses", len(courses))
 				return courses
 
 			}
 
 			token := tokenizer.Token()
-			fmt.Printf("Worker[%d]: Token[%s] Type[%s]\n", workerNum, token.Data, tokenType)
+			logger.Debug("Token", "data", token.Data, "type", tokenType)
 
 			if !(tokenType == html.StartTagToken && token.Data == "tr") {
 				continue
 			}
 
-			c, err := getCourseData(tokenizer)
+			c, err := getCourseData(tokenizer, logger)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Worker[%d]: %s\n", workerNum, err)
+				logger.Error("Failed to parse course", "err", err)
 				return []Course{}
 			}
+			rowsParsed.Inc()
 
 			// If we havent found a course yet, check if the first course is a child.
 			// If it is, then we have a bad chunk.
@@ -894,7 +973,7 @@ func parseHTML(body string, workerNum int, wg *sync.WaitGroup, verifyChunk chan<
 						n = n.CourseChild
 					}
 					n.CourseChild = &c
-					fmt.Printf("Worker[%d]: %s\n", workerNum, courseToString(courses[i]))
+					logger.Debug("Added course child", "course", lazyCourse(courses[i]))
 				} else {
 					select {
 					case <-ctx.Done():
@@ -904,16 +983,15 @@ func parseHTML(body string, workerNum int, wg *sync.WaitGroup, verifyChunk chan<
 						dbChan <- courses[i] 
 					}
 
-					fmt.Printf("Worker[%d]: Sent course to DB: %s\n", workerNum, courseToString(courses[i]))
+					logger.Debug("Sent course to DB", "course", lazyCourse(courses[i]))
 					courses = append(courses, c)
 					i++
 				}
 			}
 		}
 	}
-
-	return courses
 }
+
 func skipToFirstRow(body string) (string, error) {
 	/* skipToFirstRow takes the body and finds the first row after </thead>.
 
@@ -944,7 +1022,7 @@ func skipToFirstRow(body string) (string, error) {
 	return fragmentBody[j:], nil
 }
 
-func getChunks(body string, numChunks int, shifts int) ([]string, error) {
+func getChunks(body string, numChunks int, shifts int, logger *slog.Logger) ([]string, error) {
 	/* getChunks divides the body into chunks based on the value of numChunks.
 
 	The chunks are also divided base on the end of rows. Thus chunks are all not the
@@ -955,13 +1033,14 @@ func getChunks(body string, numChunks int, shifts int) ([]string, error) {
 		numChunks (int): The number of chunks to divide the body into.
 		shifts (int): The number of left row shifts to apply. Mainly used to handle
 				 related row spliting.
+		logger (*slog.Logger): Logger carrying the term attribute.
 	Returns:
 		([]string, error): The sliced body. Error is not nil if an error occurs during chunking.
 	*/
 	rowEnd := "</tr>"
 	chunks := []string{}
 	chunkSize := len(body) / numChunks
-	fmt.Printf("Chunk Size: %d\n", chunkSize)
+	logger.Debug("Chunking body", "chunk_size", chunkSize, "shifts", shifts)
 	i := 0
 	chunkIndex := i + chunkSize
 
@@ -1004,40 +1083,88 @@ func getChunks(body string, numChunks int, shifts int) ([]string, error) {
 	return chunks, nil
 }
 
-var m sync.Mutex
+// Number of courses an inserter collects before writing them in one batch
+const insertBatchSize = 50
+
+// How long a batch insert may take, it is not tied to the scrape context so
+// pending batches are still flushed during shutdown
+const insertTimeout = 30 * time.Second
+
 var count int32 = 0
-func inserter(coursesIn <-chan Course, group string, wg *sync.WaitGroup) {
-	/* inserters put a course into the database.
+func inserter(coursesIn <-chan Course, group string, wg *sync.WaitGroup, logger *slog.Logger) {
+	/* inserters put courses into the database in batches.
+
+	A partial batch is flushed once coursesIn is closed, so every course
+	sent by the parsers is written before the inserter returns.
 
 	Arguments:
 		coursesIn (<-chan Course): Courses sent from the parsers to put into the database.
 		group (string): The group that this course is apart of.
 		wg (*sync.WaitGroup): The waitgroup the inserter is apart of.
+		logger (*slog.Logger): Logger carrying the term attribute.
 	*/
 
 	defer wg.Done()
+	batch := make([][]byte, 0, insertBatchSize)
+	inserted := metrics.CoursesInserted.WithLabelValues(group)
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), insertTimeout)
+		defer cancel()
+		start := time.Now()
+		if err := api.InsertCourses(ctx, batch, group); err != nil {
+			metrics.InsertBatchDuration.WithLabelValues(group, "failure").Observe(time.Since(start).Seconds())
+			logger.Error("Failed to insert courses", "courses", len(batch), "err", err)
+		} else {
+			metrics.InsertBatchDuration.WithLabelValues(group, "success").Observe(time.Since(start).Seconds())
+			inserted.Add(float64(len(batch)))
+			atomic.AddInt32(&count, int32(len(batch)))
+			logger.Debug("Inserted courses", "courses", len(batch))
+		}
+		batch = batch[:0]
+	}
+
 	for c := range coursesIn {
-		atomic.AddInt32(&count, 1)
-		course, _ := json.Marshal(c)
-		api.InsertCourse(course, group)
-		fmt.Println("Inserter put a course in a database")
-	}	
+		course, err := json.Marshal(c)
+		if err != nil {
+			logger.Error("Failed to marshal course", "crn", c.Crn, "err", err)
+			continue
+		}
+		batch = append(batch, course)
+		if len(batch) == insertBatchSize {
+			flush()
+		}
+	}
+	flush()
 }
 
-func Scraper() {
+func Scraper(ctx context.Context) error {
 	/* Scraper takes 2 command line arguments, and parses the
 	The Wilkes Univeristy's Course Registar pages.
 
+	Cancelling ctx stops the download and the parsers. The courses
+	already parsed are still flushed, but the staged term is discarded
+	so the term served stays as it was before the scrape.
+
 	usage: scraper [F | Sp] year
+
+	Arguments:
+		ctx (context.Context): A context that stops the scrape when cancelled.
+
+	Returns:
+		error: Error during scraping, ctx.Err() if cancelled, or nil
 	*/
 
-	log.Println("Scraper service started")
+	slog.Info("Scraper service started")
 
 	// Get args
 	args := os.Args
 
 	if (len(args) != 2) {
-		panic(errors.New("usage: scraper semester year"))
+		return errors.New("usage: scraper semester year")
 	}
 
 	semester := args[0]
@@ -1052,35 +1179,56 @@ func Scraper() {
 		}
 	}
 	if !(found) {
-		panic(errors.New(fmt.Sprintf("error: bad semester, Got %s", semester)))
+		return fmt.Errorf("error: bad semester, Got %s", semester)
 	}
 
 	// Verify the year
 	yearInt, err := strconv.Atoi(year)
 	if err != nil {
-		panic(errors.New(fmt.Sprintf("error: bad year, Got %s", year)))
+		return fmt.Errorf("error: bad year, Got %s", year)
 	}
 
-	body, err := getHTML(fmt.Sprintf("https://rosters.wilkes.edu/scheds/courses%s%d.html", semester, yearInt))
+	group := semester + year
+	logger := slog.With("term", group)
+
+	start := time.Now()
+	outcome := "failure"
+	defer func() {
+		metrics.ScrapeDuration.WithLabelValues(group, outcome).Observe(time.Since(start).Seconds())
+	}()
+
+	body, err := getHTML(ctx, fmt.Sprintf("https://rosters.wilkes.edu/scheds/courses%s%d.html", semester, yearInt))
 	if err != nil {
-		panic(err)
+		return err
 	}
 
 	body, err = skipToFirstRow(body)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	f, err := os.Create("trace.out")
-	if err != nil {
-		panic(err)
+	// Courses are written to a staging collection that replaces the
+	// term once the scrape succeeds, a failed scrape leaves it as it was
+	if err = api.StageTerm(ctx, group); err != nil {
+		return err
 	}
-	defer f.Close()
-	err = trace.Start(f)
+	defer func() {
+		if outcome == "success" {
+			return
+		}
+		discardCtx, cancel := context.WithTimeout(context.Background(), insertTimeout)
+		defer cancel()
+		if err := api.DiscardTerm(discardCtx, group); err != nil {
+			logger.Error("Failed to discard staged courses", "err", err)
+		}
+	}()
+
+	// Tracing and profiling are opt-in through the configuration
+	stopDiagnostics, err := startDiagnostics(config.LoadConfig(), logger)
 	if err != nil {
-		panic(err)
+		return err
 	}
-	defer trace.Stop()
+	defer stopDiagnostics()
 
 	// 3 inserters, the rest are parsers
 	inserters := 3
@@ -1088,24 +1236,34 @@ func Scraper() {
 
 	shifts := 0
 	sendDB := make(chan Course, parsers)
-	group := semester + year
 	var insertersWg sync.WaitGroup
 
 	// Create inserters
-	for _ = range(inserters) {
+	for range(inserters) {
 		insertersWg.Add(1)
-		go inserter(sendDB, group, &insertersWg)
+		go inserter(sendDB, group, &insertersWg, logger)
+	}
+
+	// Once the parsers are done, close the channel so the inserters
+	// flush what they have left
+	flushInserters := func() {
+		close(sendDB)
+		insertersWg.Wait()
 	}
 
 	for {
-		ctx, cancel := context.WithCancel(context.Background())
+		parseCtx, cancel := context.WithCancel(ctx)
 
 		// Get chunks of the body
-		chunks, err := getChunks(body, parsers - 1, shifts)
+		chunks, err := getChunks(body, parsers - 1, shifts, logger)
 		if err != nil {
-			panic(err)
+			cancel()
+			flushInserters()
+			return err
 		} else if len(chunks) != parsers {
-			panic(errors.New(fmt.Sprintf("error: chunks do not match requested parsers. chunks: %d, parsers: %d",len(chunks), parsers)))
+			cancel()
+			flushInserters()
+			return fmt.Errorf("error: chunks do not match requested parsers. chunks: %d, parsers: %d",len(chunks), parsers)
 		}
 
 		verifyChan := make(chan bool, parsers)
@@ -1115,42 +1273,72 @@ func Scraper() {
 		// Spawn workers
 		for i := range(parsers) {
 			parsersWg.Add(1)
-			go parseHTML(chunks[i], i, &parsersWg, verifyChan, sendDB, ctx)
+			go parseHTML(chunks[i], i, group, &parsersWg, verifyChan, sendDB, parseCtx, logger)
 		}
 
 		// Wait for each worker to determine if there chunk is good or bad.
 		// If we find out a chunk is bad, redo the chunks
 		goodChunks := 0
 		badChunkFound := false
-		for res := range verifyChan {
-			if (!res) {
+		for !badChunkFound && goodChunks < parsers {
+			select {
+			case <-ctx.Done():
+				// Shutting down, stop the parsers and keep what was sent
 				cancel()
-				// Wait for the parsers to finish
 				parsersWg.Wait()
-				badChunkFound = true
-				break
-			}
-			goodChunks++
-			if (goodChunks == parsers) {
-				close(verifyChan)
+				flushInserters()
+				outcome = "cancelled"
+				logger.Warn("Scrape cancelled", "courses", count)
+				return ctx.Err()
+			case res := <-verifyChan:
+				if (!res) {
+					cancel()
+					// Wait for the parsers to finish
+					parsersWg.Wait()
+					badChunkFound = true
+				} else {
+					goodChunks++
+				}
 			}
 		}
 
 		// If a bad chunk is found, increase the shift and try again
 		if (badChunkFound) {
 			shifts++
+			metrics.BadChunkRetries.WithLabelValues(group).Inc()
+			logger.Info("Bad chunk found, rechunking", "shifts", shifts)
 
 		} else {
 
 			parsersWg.Wait()
+			cancel()
+			if ctx.Err() != nil {
+				flushInserters()
+				outcome = "cancelled"
+				logger.Warn("Scrape cancelled", "courses", count)
+				return ctx.Err()
+			}
 			break
 
 		}
 	}
 
 	// The parsers are done, so close the channel
-	close(sendDB)
-	insertersWg.Wait()
+	flushInserters()
 
-	fmt.Printf("Done Scraping. Parsed %d Courses from %s%s\n", count, semester, year)
+	ctx, cancel := context.WithTimeout(context.Background(), insertTimeout)
+	defer cancel()
+	if err := api.PublishTerm(ctx, group); err != nil {
+		return err
+	}
+	if err := api.RecordTerm(ctx, group); err != nil {
+		logger.Error("Failed to record term", "err", err)
+	}
+	if err := api.RecordScrape(ctx, group, int(count)); err != nil {
+		logger.Error("Failed to record scrape", "err", err)
+	}
+
+	outcome = "success"
+	logger.Info("Done Scraping", "courses", count, "duration", time.Since(start))
+	return nil
 }